@@ -0,0 +1,109 @@
+package jh
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AuthOptions configures [AuthWith].
+type AuthOptions struct {
+	// Header holds the credentials.
+	// The default is Authorization.
+	Header string
+
+	// Scheme is the authentication scheme expected at the
+	// start of the header, eg "Bearer" or "Basic". Basic
+	// credentials are decoded and passed to the verify func
+	// as "user:password". An empty Scheme passes the whole
+	// header, which suits headers like X-API-Key.
+	Scheme string
+
+	// Realm is sent in the WWW-Authenticate header
+	// of 401 responses when it's set.
+	Realm string
+}
+
+// Auth returns middleware that passes the request's Bearer token
+// to verify. The principal verify returns is stored in the context
+// where wrapped functions can get it using [Principal]. Requests
+// without a token, or whose token verify rejects, get a 401 [Error].
+// verify can return an [Error] itself to use another status,
+// eg a 403 for tokens that are valid but not allowed.
+//
+//	h := Chain(HandlerFunc(me), Auth(func(ctx context.Context, token string) (any, error) {
+//		return users.ByToken(ctx, token)
+//	}))
+func Auth(verify func(ctx context.Context, token string) (any, error)) func(http.Handler) http.Handler {
+	return AuthWith(AuthOptions{Scheme: "Bearer"}, verify)
+}
+
+// AuthWith is like [Auth] but reads the
+// credentials as configured by opts.
+func AuthWith(opts AuthOptions, verify func(ctx context.Context, token string) (any, error)) func(http.Handler) http.Handler {
+	if opts.Header == "" {
+		opts.Header = "Authorization"
+	}
+	challenge := opts.Scheme
+	if opts.Realm != "" {
+		challenge += ` realm="` + opts.Realm + `"`
+	}
+	unauthorized := func(w http.ResponseWriter, r *http.Request, msg string) {
+		if challenge != "" {
+			w.Header().Set("WWW-Authenticate", challenge)
+		}
+		ErrHandler(r.Context(), w, Error{Code: http.StatusUnauthorized, Message: msg})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := credentials(r.Header.Get(opts.Header), opts.Scheme)
+			if !ok {
+				unauthorized(w, r, "missing credentials")
+				return
+			}
+			principal, err := verify(r.Context(), token)
+			if err != nil {
+				var jhe Error
+				if errors.As(err, &jhe) && jhe.Code != http.StatusUnauthorized {
+					ErrHandler(r.Context(), w, jhe)
+					return
+				}
+				unauthorized(w, r, "invalid credentials")
+				return
+			}
+			ctx := context.WithValue(r.Context(), principalKey, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// credentials returns the credentials in the header value
+// hdr that uses the given scheme.
+func credentials(hdr, scheme string) (string, bool) {
+	if scheme == "" {
+		return hdr, hdr != ""
+	}
+	s, token, ok := strings.Cut(hdr, " ")
+	if !ok || !strings.EqualFold(s, scheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	if strings.EqualFold(scheme, "Basic") {
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil || !strings.Contains(string(b), ":") {
+			return "", false
+		}
+		token = string(b)
+	}
+	return token, token != ""
+}
+
+// Principal returns the principal that the verify func of
+// [Auth] returned for the request. It returns nil when
+// the request wasn't served by Auth.
+func Principal(ctx context.Context) any {
+	return ctx.Value(principalKey)
+}
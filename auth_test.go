@@ -0,0 +1,76 @@
+package jh
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	me, _ := Handler(func(ctx context.Context) (*string, error) {
+		name := Principal(ctx).(string)
+		return &name, nil
+	}, ErrHandler)
+	verify := func(ctx context.Context, token string) (any, error) {
+		switch token {
+		case "good", "ann:secret":
+			return "ann", nil
+		case "banned":
+			return nil, Error{Code: http.StatusForbidden, Message: "banned"}
+		}
+		return nil, errors.New("unknown token")
+	}
+	var (
+		bearer = Auth(verify)(me)
+		basic  = AuthWith(AuthOptions{Scheme: "Basic", Realm: "api"}, verify)(me)
+		apiKey = AuthWith(AuthOptions{Header: "X-API-Key"}, verify)(me)
+		b64    = func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	)
+
+	cases := []struct {
+		h             http.Handler
+		header, value string
+		wantStatus    int
+		wantBody      string
+		wantChallenge string
+	}{
+		{bearer, "Authorization", "Bearer good", 200, `"ann"`, ""},
+		{bearer, "Authorization", "bearer good", 200, `"ann"`, ""},
+		{bearer, "Authorization", "", 401, `{"message":"missing credentials"}`, "Bearer"},
+		{bearer, "Authorization", "Basic good", 401, `{"message":"missing credentials"}`, "Bearer"},
+		{bearer, "Authorization", "Bearer bad", 401, `{"message":"invalid credentials"}`, "Bearer"},
+		{bearer, "Authorization", "Bearer banned", 403, `{"message":"banned"}`, ""},
+		{basic, "Authorization", "Basic " + b64("ann:secret"), 200, `"ann"`, ""},
+		{basic, "Authorization", "Basic " + b64("ann:wrong"), 401, `{"message":"invalid credentials"}`, `Basic realm="api"`},
+		{basic, "Authorization", "Basic !!", 401, `{"message":"missing credentials"}`, `Basic realm="api"`},
+		{apiKey, "X-API-Key", "good", 200, `"ann"`, ""},
+		{apiKey, "X-API-Key", "", 401, `{"message":"missing credentials"}`, ""},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/me", nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.value != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		c.h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != c.wantChallenge {
+			t.Errorf("case %d: got WWW-Authenticate %q want %q", i, got, c.wantChallenge)
+		}
+	}
+
+	if p := Principal(context.Background()); p != nil {
+		t.Errorf("got %v want nil", p)
+	}
+}
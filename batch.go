@@ -0,0 +1,153 @@
+package jh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+)
+
+// ErrBatchReq is returned by [Batch] when
+// wrappedFunc doesn't take a request.
+var ErrBatchReq = errors.New("jh: batch: wrappedFunc must take a request")
+
+// A BatchResult is the outcome of one request of a batch.
+// Body holds the response of successful requests, Error
+// the error of failed ones.
+type BatchResult struct {
+	Status int    `json:"status"`
+	Body   any    `json:"body,omitempty"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+// Batch wraps wrappedFunc, which has one of the forms accepted by
+// [Handler] that take a request, in a handler whose request body is
+// a JSON array of requests. wrappedFunc is called with each of them
+// in order and the response is a JSON array with a [BatchResult]
+// for each request:
+//
+//	[{"x": 1, "y": 2}, {"x": "one"}]
+//
+// gets
+//
+//	[{"status": 200, "body": {"sum": 3}}, {"status": 400, "error": {"message": "..."}}]
+//
+// A failed request doesn't stop the others. Its status is the
+// Code of an [Error], a code added with [RegisterError] or 500.
+// Requests are only decoded from the array, so path, query,
+// header and cookie tags aren't bound. The batch as a whole
+// fails, through errFunc, when its body isn't an array.
+//
+// errFunc and opts are used as they are by [Handler]. Options about
+// the body, like [MaxBodyBytes] and [RequireContentType], apply to
+// the batch's body. [WithSchema] and [Example] describe each of
+// its requests, and [ValidationStatus] applies to both.
+func Batch(wrappedFunc any, errFunc func(context.Context, http.ResponseWriter, error), opts ...Option) (http.Handler, error) {
+	inner, err := Handler(wrappedFunc, errFunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	one := inner.(*handler)
+	if one.newReq == nil {
+		return nil, ErrBatchReq
+	}
+
+	h := newHandler(errFunc, opts)
+	// these describe the requests, not the array of them
+	h.schema = nil
+	h.exampleReq, h.exampleResp = nil, nil
+	h.reqType = reflect.SliceOf(one.reqType)
+	h.respType = reflect.TypeOf([]BatchResult(nil))
+	h.newReq = func() any {
+		return new([]json.RawMessage)
+	}
+	h.call = func(ctx context.Context, req any) (any, error) {
+		raws := *req.(*[]json.RawMessage)
+		results := make([]BatchResult, len(raws))
+		for i, raw := range raws {
+			results[i] = one.callOne(ctx, raw)
+		}
+		return results, nil
+	}
+	return h, nil
+}
+
+// callOne decodes raw and calls the wrapped function with it.
+// Each call gets its own state so [WithStatus] only
+// changes the status of its own result.
+func (h *handler) callOne(ctx context.Context, raw json.RawMessage) (res BatchResult) {
+	st := &state{}
+	ctx = context.WithValue(ctx, stateKey, st)
+	defer func() {
+		if !h.recoverPanics {
+			return
+		}
+		v := recover()
+		if v == nil {
+			return
+		}
+		var err error = PanicError{Value: v, Stack: debug.Stack()}
+		if h.onPanic != nil {
+			err = h.onPanic(ctx, v, err.(PanicError).Stack)
+		}
+		res = batchError(err)
+	}()
+
+	if h.schema != nil {
+		if err := h.schema.check(raw); err != nil {
+			return batchError(h.invalid(err))
+		}
+	}
+	if h.formatTimes {
+		formatted, err := formatTimes(h.reqType, raw)
+		if err != nil {
+			return batchError(h.invalid(err))
+		}
+		raw = formatted
+	}
+	req := h.newReq()
+	if err := h.json.Decode(bytes.NewReader(raw), req); err != nil {
+		if isSemantic(err) {
+			return batchError(h.invalid(decodeError(err)))
+		}
+		return batchError(decodeError(err))
+	}
+	if h.checkRequired {
+		if err := checkRequired(h.reqType, raw); err != nil {
+			return batchError(h.invalid(err))
+		}
+	}
+	if err := h.check(req); err != nil {
+		return batchError(err)
+	}
+	resp, err := h.call(ctx, req)
+	if err != nil {
+		return batchError(err)
+	}
+	// results have no headers
+	resp, _ = unwrapResponse(st, resp)
+
+	res.Status = http.StatusOK
+	switch {
+	case h.noContent || resp == nil:
+		res.Status = http.StatusNoContent
+		resp = nil
+	default:
+		if sc, ok := resp.(StatusCoder); ok {
+			res.Status = sc.StatusCode()
+		}
+	}
+	if st.status != 0 {
+		res.Status = st.status
+	}
+	res.Body = resp
+	return res
+}
+
+func batchError(err error) BatchResult {
+	jhe, _ := responseError(err)
+	return BatchResult{Status: jhe.Code, Error: &jhe}
+}
@@ -0,0 +1,145 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	errGone := errors.New("gone")
+	defer func(s []registeredError) { registeredErrors.s = s }(registeredErrors.s)
+	RegisterError(errGone, 410)
+
+	h, err := Batch(func(ctx context.Context, p point) (*point, error) {
+		switch {
+		case p.X < 0:
+			return nil, errGone
+		case p.X == 0:
+			return nil, nil
+		case p.X == 9:
+			panic("nine")
+		case p.Y == 1:
+			WithStatus(ctx, 201)
+		}
+		return &point{p.Y, p.X}, nil
+	}, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			`[{"x":1,"y":2},{"x":"one"},{"x":-1},{"x":0},{"x":2,"y":1},{"x":9}]`,
+			200,
+			`[{"status":200,"body":{"x":2,"y":1}},` +
+				`{"status":400,"error":{"message":"field \"x\" must be an integer, not a string"}},` +
+				`{"status":410,"error":{"message":"gone"}},` +
+				`{"status":204},` +
+				`{"status":201,"body":{"x":1,"y":2}},` +
+				`{"status":500,"error":{"message":"jh: panic: nine"}}]`,
+		},
+		{`[]`, 200, `[]`},
+		{`{"x":1}`, 400, `{"message":"request body must be an array, not an object"}`},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/batch", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s\nwant %s", i, got, c.wantBody)
+		}
+	}
+
+	if _, err := Batch(func(ctx context.Context) error { return nil }, ErrHandler); err != ErrBatchReq {
+		t.Errorf("got %v want %v", err, ErrBatchReq)
+	}
+}
+
+func TestBatchInternalError(t *testing.T) {
+	defer func(code int, msg string) {
+		DefaultErrorStatus, DefaultErrorMessage = code, msg
+	}(DefaultErrorStatus, DefaultErrorMessage)
+	DefaultErrorStatus, DefaultErrorMessage = 503, "internal error"
+
+	h, err := Batch(func(ctx context.Context, p point) (*point, error) {
+		if p.X < 0 {
+			return nil, errors.New("dial tcp 10.0.0.1:5432: connection refused")
+		}
+		return nil, BadRequest("x must be negative")
+	}, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/batch", strings.NewReader(`[{"x":-1},{"x":1}]`)))
+	want := `[{"status":503,"error":{"message":"internal error"}},` +
+		`{"status":400,"error":{"message":"x must be negative"}}]`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
+
+func TestBatchOptions(t *testing.T) {
+	echo := func(ctx context.Context, p point) (*point, error) {
+		return &p, nil
+	}
+	cases := []struct {
+		opts       []Option
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			[]Option{WithSchema([]byte(`{"type":"object","required":["x"]}`))},
+			`[{"x":1},{"y":2}]`,
+			200,
+			`[{"status":200,"body":{"x":1,"y":0}},` +
+				`{"status":400,"error":{"message":"invalid x: required","details":{"x":"required"}}}]`,
+		},
+		{
+			[]Option{ValidationStatus(422)},
+			`[{"x":"one"},{"x":1`,
+			400,
+			``,
+		},
+		{
+			[]Option{ValidationStatus(422)},
+			`[{"x":"one"},{"x":1}]`,
+			200,
+			`[{"status":422,"error":{"message":"field \"x\" must be an integer, not a string"}},` +
+				`{"status":200,"body":{"x":1,"y":0}}]`,
+		},
+		{
+			[]Option{ValidationStatus(422)},
+			`{"x":1}`,
+			422,
+			`{"message":"request body must be an array, not an object"}`,
+		},
+	}
+	for i, c := range cases {
+		h, err := Batch(echo, ErrHandler, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/batch", strings.NewReader(c.body)))
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); c.wantBody != "" && got != c.wantBody {
+			t.Errorf("case %d: got = %s\nwant %s", i, got, c.wantBody)
+		}
+	}
+}
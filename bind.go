@@ -0,0 +1,392 @@
+package jh
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hasTag reports whether t, or the struct t points to,
+// has an exported field with the given tag.
+func hasTag(t reflect.Type, tag string) bool {
+	for _, sf := range structFields(t) {
+		if _, ok := sf.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldCache sync.Map // reflect.Type -> []reflect.StructField
+
+// structFields returns the exported fields of t, or the struct
+// t points to, that binders look at. Like encoding/json does, the
+// fields of embedded structs, or pointers to them, are promoted:
+// they're listed instead of the embedded field, with an Index
+// leading to them from t. See [fieldByIndex].
+func structFields(t reflect.Type) []reflect.StructField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]reflect.StructField)
+	}
+	fields := appendFields(nil, t, nil, map[reflect.Type]bool{})
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func appendFields(fields []reflect.StructField, t reflect.Type, index []int, seen map[reflect.Type]bool) []reflect.StructField {
+	if seen[t] {
+		return fields
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		sf.Index = append(append([]int(nil), index...), i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		// unexported embedded pointers can't be allocated
+		promoted := sf.IsExported() || sf.Type.Kind() != reflect.Pointer
+		if sf.Anonymous && ft.Kind() == reflect.Struct && ft != timeType && promoted {
+			fields = appendFields(fields, ft, sf.Index, seen)
+			continue
+		}
+		if sf.IsExported() {
+			fields = append(fields, sf)
+		}
+	}
+	return fields
+}
+
+// fieldByIndex returns the field of the struct v points to at
+// index, allocating the nil embedded structs on its way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		v = structValue(v).Field(i)
+	}
+	return v
+}
+
+// bindQuery sets the fields of req tagged with
+// `query:"name"` from the URL's query parameters.
+func bindQuery(req any, r *http.Request) error {
+	q := r.URL.Query()
+	return bind(reflect.ValueOf(req), "query", "query parameter", false, func(name string) ([]string, bool) {
+		vals, ok := q[name]
+		return vals, ok
+	})
+}
+
+// bindPath sets the fields of req tagged with `path:"name"` from
+// the wildcards of the request's [http.ServeMux] pattern.
+// Missing path values are an error.
+func bindPath(req any, r *http.Request) error {
+	return bind(reflect.ValueOf(req), "path", "path parameter", true, func(name string) ([]string, bool) {
+		val := r.PathValue(name)
+		return []string{val}, val != ""
+	})
+}
+
+// bindHeader sets the fields of req tagged with
+// `header:"name"` from the request's headers.
+func bindHeader(req any, r *http.Request) error {
+	return bind(reflect.ValueOf(req), "header", "header", false, func(name string) ([]string, bool) {
+		vals := r.Header.Values(name)
+		return vals, len(vals) > 0
+	})
+}
+
+// bindCookie sets the fields of req tagged with
+// `cookie:"name"` from the request's cookies.
+func bindCookie(req any, r *http.Request) error {
+	cookies := r.Cookies()
+	return bind(reflect.ValueOf(req), "cookie", "cookie", false, func(name string) ([]string, bool) {
+		var vals []string
+		for _, c := range cookies {
+			if c.Name == name {
+				vals = append(vals, c.Value)
+			}
+		}
+		return vals, len(vals) > 0
+	})
+}
+
+// bindForm sets the fields of req tagged with `form:"name"`
+// from the values of a parsed form.
+func bindForm(req any, form url.Values) error {
+	return bind(reflect.ValueOf(req), "form", "form field", false, func(name string) ([]string, bool) {
+		vals, ok := form[name]
+		return vals, ok
+	})
+}
+
+// bindMultipart binds the values of a parsed multipart form like
+// [bindForm] and sets fields tagged with `file:"name"` from its
+// files. File fields must have type *multipart.FileHeader
+// or []*multipart.FileHeader.
+func bindMultipart(req any, form *multipart.Form) error {
+	if err := bindForm(req, form.Value); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(req)
+	for _, sf := range structFields(v.Type()) {
+		name, ok := sf.Tag.Lookup("file")
+		if !ok || name == "-" {
+			continue
+		}
+		fhs := form.File[name]
+		if len(fhs) == 0 {
+			continue
+		}
+		switch f := fieldByIndex(v, sf.Index); f.Type() {
+		case fileHeaderType:
+			f.Set(reflect.ValueOf(fhs[0]))
+		case fileHeadersType:
+			f.Set(reflect.ValueOf(fhs))
+		default:
+			return fmt.Errorf("jh: file field %s: %w %s", sf.Name, errUnsupported, f.Type())
+		}
+	}
+	return nil
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindTags are the tags binders set fields from, with
+// the kind of value they name in error messages.
+var bindTags = []struct{ tag, kind string }{
+	{"query", "query parameter"},
+	{"path", "path parameter"},
+	{"header", "header"},
+	{"cookie", "cookie"},
+	{"form", "form field"},
+}
+
+// checkBindable returns an error when a field of t, or the
+// struct t points to, is tagged for binding but has a type
+// the binders can't set, so it's found before serving requests.
+func checkBindable(t reflect.Type) error {
+	for _, sf := range structFields(t) {
+		for _, bt := range bindTags {
+			name, ok := sf.Tag.Lookup(bt.tag)
+			if ok && name != "-" && !settable(sf.Type) {
+				return fmt.Errorf("jh: %s field %s: %w %s", bt.kind, sf.Name, errUnsupported, sf.Type)
+			}
+		}
+		name, ok := sf.Tag.Lookup("file")
+		if ok && name != "-" && sf.Type != fileHeaderType && sf.Type != fileHeadersType {
+			return fmt.Errorf("jh: file field %s: %w %s", sf.Name, errUnsupported, sf.Type)
+		}
+	}
+	return nil
+}
+
+// bind sets the fields of the struct pointed to by v that have
+// the given tag. The tag's value is the name passed to get.
+// Fields are left alone when get doesn't find their name
+// unless required is set or they're tagged `jh:"required"`.
+// kind describes the values' source in error messages.
+func bind(
+	v reflect.Value,
+	tag, kind string,
+	required bool,
+	get func(name string) ([]string, bool),
+) error {
+	for _, sf := range structFields(v.Type()) {
+		name, ok := sf.Tag.Lookup(tag)
+		if !ok || name == "-" {
+			continue
+		}
+		vals, ok := get(name)
+		if !ok || len(vals) == 0 {
+			if required || isRequired(sf) {
+				return Error{
+					Code:    http.StatusBadRequest,
+					Message: fmt.Sprintf("missing %s %q", kind, name),
+				}
+			}
+			continue
+		}
+		vals, err := rfc3339Values(sf, vals)
+		if err == nil {
+			err = setValue(fieldByIndex(v, sf.Index), vals)
+		}
+		if errors.Is(err, errUnsupported) {
+			return fmt.Errorf("jh: %s field %s: %w", kind, sf.Name, err)
+		}
+		if err != nil {
+			return Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid %s %q: %s", kind, name, err),
+			}
+		}
+	}
+	return nil
+}
+
+// setDefaults sets the fields of the struct pointed to by v that
+// are tagged with `default:"value"` and still have their zero value.
+// Slices get the tag's comma separated values.
+func setDefaults(v reflect.Value) error {
+	for _, sf := range structFields(v.Type()) {
+		def, ok := sf.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		f := fieldByIndex(v, sf.Index)
+		if !f.IsZero() {
+			continue
+		}
+		vals := []string{def}
+		if sf.Type.Kind() == reflect.Slice {
+			vals = strings.Split(def, ",")
+		}
+		vals, err := rfc3339Values(sf, vals)
+		if err == nil {
+			err = setValue(f, vals)
+		}
+		if err != nil {
+			return fmt.Errorf("jh: default for field %s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// isRequired reports whether sf is tagged `jh:"required"`.
+func isRequired(sf reflect.StructField) bool {
+	for _, opt := range strings.Split(sf.Tag.Get("jh"), ",") {
+		if opt == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// structValue follows v's pointers, allocating
+// nil ones, and returns the struct at the end.
+func structValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+var errUnsupported = errors.New("unsupported type")
+
+// setValue converts vals to v's type and stores the result in v.
+// Slices get every value, other types get the first.
+func setValue(v reflect.Value, vals []string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), vals); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i := range vals {
+			if err := setString(s.Index(i), vals[i]); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setString(v, vals[0])
+}
+
+// settable reports whether [setValue] can set a value of type t.
+func settable(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("%q is not an RFC 3339 time", s)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a bool", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an %s", s, v.Kind())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a %s", s, v.Kind())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a %s", s, v.Kind())
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("%w %s", errUnsupported, v.Type())
+	}
+	return nil
+}
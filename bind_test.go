@@ -0,0 +1,610 @@
+package jh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type search struct {
+	Q      string   `query:"q"`
+	Limit  int      `query:"limit"`
+	Exact  bool     `query:"exact"`
+	Min    *float64 `query:"min"`
+	Tags   []string `query:"tag"`
+	Filter string   `json:"filter"`
+}
+
+func TestBindQuery(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, s search) (*search, error) {
+		return &s, nil
+	}, ErrHandler)
+
+	cases := []struct {
+		method, target, body string
+		wantStatus           int
+		wantBody             string
+	}{
+		{
+			"GET", "/?q=go&limit=5&exact=true&min=1.5&tag=a&tag=b", "",
+			200, `{"Q":"go","Limit":5,"Exact":true,"Min":1.5,"Tags":["a","b"],"filter":""}`,
+		},
+		{
+			"GET", "/", "",
+			200, `{"Q":"","Limit":0,"Exact":false,"Min":null,"Tags":null,"filter":""}`,
+		},
+		{
+			"POST", "/?limit=5", `{"filter":"x","Limit":1}`,
+			200, `{"Q":"","Limit":5,"Exact":false,"Min":null,"Tags":null,"filter":"x"}`,
+		},
+		{
+			"GET", "/?limit=five", "",
+			400, `{"message":"invalid query parameter \"limit\": \"five\" is not an int"}`,
+		},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.target, got, c.wantBody)
+		}
+	}
+}
+
+func TestBindQueryPointer(t *testing.T) {
+	var (
+		r   = httptest.NewRequest("GET", "/?q=go", nil)
+		rec = httptest.NewRecorder()
+	)
+	HandlerFunc(func(ctx context.Context, s *search) (*search, error) {
+		return s, nil
+	}).ServeHTTP(rec, r)
+	got, _ := io.ReadAll(rec.Result().Body)
+	if !strings.Contains(string(got), `"Q":"go"`) {
+		t.Errorf("got = %s", got)
+	}
+}
+
+type Page struct {
+	Limit int    `query:"limit" default:"20" json:"limit"`
+	After string `query:"after" json:"after,omitempty"`
+}
+
+type tenant struct {
+	Tenant string `header:"X-Tenant-ID" jh:"required" json:"tenant"`
+}
+
+type Owner struct {
+	Owner string `path:"owner" json:"owner"`
+}
+
+type listRepos struct {
+	Page
+	tenant
+	*Owner
+	Sort string `query:"sort" json:"sort"`
+}
+
+func TestBindEmbedded(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, r listRepos) (*listRepos, error) {
+		return &r, nil
+	}, ErrHandler)
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{owner}/repos", h)
+
+	cases := []struct {
+		target, tenant string
+		wantStatus     int
+		wantBody       string
+	}{
+		{
+			"/users/rs/repos?limit=5&after=r7&sort=name", "acme",
+			200, `{"limit":5,"after":"r7","tenant":"acme","owner":"rs","sort":"name"}`,
+		},
+		{"/users/rs/repos", "acme", 200, `{"limit":20,"tenant":"acme","owner":"rs","sort":""}`},
+		{"/users/rs/repos?limit=x", "acme", 400, `{"message":"invalid query parameter \"limit\": \"x\" is not an int"}`},
+		{"/users/rs/repos", "", 400, `{"message":"missing header \"X-Tenant-ID\""}`},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", c.target, nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.tenant != "" {
+			r.Header.Set("X-Tenant-ID", c.tenant)
+		}
+		mux.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.target, got, c.wantBody)
+		}
+	}
+
+	var got []string
+	for _, p := range parameters(reflect.TypeOf(listRepos{})) {
+		got = append(got, p.(map[string]any)["name"].(string))
+	}
+	if want := []string{"owner", "limit", "after", "sort", "X-Tenant-ID"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got parameters %q want %q", got, want)
+	}
+}
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+}
+
+func TestBindPath(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, u user) (*user, error) {
+		return &u, nil
+	}, ErrHandler)
+	mux := http.NewServeMux()
+	mux.Handle("PUT /users/{id}", h)
+	mux.Handle("PUT /people/{name}", h)
+
+	cases := []struct {
+		target, body string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"/users/7", `{"id":1,"name":"x"}`, 200, `{"id":7,"name":"x"}`},
+		{"/users/seven", `{}`, 400, `{"message":"invalid path parameter \"id\": \"seven\" is not an int"}`},
+		{"/people/x", `{}`, 400, `{"message":"missing path parameter \"id\""}`},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("PUT", c.target, strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		mux.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.target, got, c.wantBody)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	type list struct {
+		Limit   int           `query:"limit" default:"20"`
+		Sort    string        `query:"sort" default:"name"`
+		Desc    bool          `json:"desc" default:"true"`
+		Ratio   float64       `default:"0.5"`
+		Wait    time.Duration `query:"wait" default:"1s"`
+		Cursor  *string       `query:"cursor" default:"start"`
+		Fields  []string      `query:"field" default:"id,name"`
+		Ignored int           `json:"ignored"`
+	}
+	h, _ := Handler(func(ctx context.Context, l list) (*list, error) {
+		return &l, nil
+	}, ErrHandler)
+
+	cases := []struct {
+		target, body string
+		wantStatus   int
+		wantBody     string
+	}{
+		{
+			"/", "{}",
+			200, `{"Limit":20,"Sort":"name","desc":true,"Ratio":0.5,"Wait":1000000000,"Cursor":"start","Fields":["id","name"],"ignored":0}`,
+		},
+		{
+			"/?limit=5&sort=age&wait=2m&cursor=x&field=id", `{"desc":false}`,
+			200, `{"Limit":5,"Sort":"age","desc":true,"Ratio":0.5,"Wait":120000000000,"Cursor":"x","Fields":["id"],"ignored":0}`,
+		},
+		{
+			"/?wait=soon", "{}",
+			400, `{"message":"invalid query parameter \"wait\": \"soon\" is not a duration"}`,
+		},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.target, got, c.wantBody)
+		}
+	}
+
+	type badDefault struct {
+		N int `default:"many"`
+	}
+	bad := func(ctx context.Context, b badDefault) (*badDefault, error) {
+		return &b, nil
+	}
+	if _, err := Handler(bad, ErrHandler); err == nil || !strings.Contains(err.Error(), "default for field N") {
+		t.Errorf("got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("HandlerFunc didn't panic")
+		}
+	}()
+	HandlerFunc(bad)
+}
+
+func TestBindTime(t *testing.T) {
+	type since struct {
+		Since   time.Time     `query:"since"`
+		Until   *time.Time    `header:"X-Until"`
+		Timeout time.Duration `query:"timeout"`
+	}
+	h := HandlerFunc(func(ctx context.Context, s since) (*since, error) {
+		return &s, nil
+	})
+
+	cases := []struct {
+		target, until string
+		wantStatus    int
+		wantBody      string
+	}{
+		{
+			"/?since=2024-01-01T00:00:00Z&timeout=30s", "2024-02-01T12:30:00+01:00",
+			200, `{"Since":"2024-01-01T00:00:00Z","Until":"2024-02-01T12:30:00+01:00","Timeout":30000000000}`,
+		},
+		{
+			"/?since=2024-01-01", "",
+			400, `{"message":"invalid query parameter \"since\": \"2024-01-01\" is not an RFC 3339 time"}`,
+		},
+		{
+			"/", "tomorrow",
+			400, `{"message":"invalid header \"X-Until\": \"tomorrow\" is not an RFC 3339 time"}`,
+		},
+		{
+			"/?timeout=30", "",
+			400, `{"message":"invalid query parameter \"timeout\": \"30\" is not a duration"}`,
+		},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", c.target, nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.until != "" {
+			r.Header.Set("X-Until", c.until)
+		}
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.target, got, c.wantBody)
+		}
+	}
+}
+
+type signedUpload struct {
+	Name   string `json:"name"`
+	Signer string `json:"signer"`
+	Size   int    `json:"size" default:"1"`
+}
+
+func (u *signedUpload) Bind(r *http.Request) error {
+	sig := r.Header.Get("X-Signature")
+	switch {
+	case sig == "":
+		return errors.New("missing signature")
+	case sig == "forged":
+		return Error{Code: 403, Message: "bad signature"}
+	}
+	u.Signer = sig + ":" + u.Name
+	return nil
+}
+
+type byValue struct {
+	Host string `json:"host"`
+}
+
+func (b byValue) Bind(r *http.Request) error {
+	if r.Host != "example.com" {
+		return fmt.Errorf("unknown host %s", r.Host)
+	}
+	return nil
+}
+
+func TestBinder(t *testing.T) {
+	reflected := func(f any) http.Handler {
+		h, _ := Handler(f, nil)
+		return h
+	}
+	cases := []struct {
+		h          http.Handler
+		host, sig  string
+		wantStatus int
+		wantBody   string
+	}{
+		{HandlerFunc(echo[signedUpload]), "example.com", "k1", 200, `{"name":"a.txt","signer":"k1:a.txt","size":1}`},
+		{HandlerFunc(echo[signedUpload]), "example.com", "", 400, `{"message":"missing signature"}`},
+		{HandlerFunc(echo[signedUpload]), "example.com", "forged", 403, `{"message":"bad signature"}`},
+		{HandlerFunc(echo[*signedUpload]), "example.com", "k1", 200, `{"name":"a.txt","signer":"k1:a.txt","size":1}`},
+		{reflected(echo[signedUpload]), "example.com", "k1", 200, `{"name":"a.txt","signer":"k1:a.txt","size":1}`},
+		{reflected(echo[byValue]), "example.com", "", 200, `{"host":""}`},
+		{reflected(echo[byValue]), "other.com", "", 400, `{"message":"unknown host other.com"}`},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a.txt","signer":"me"}`))
+			rec = httptest.NewRecorder()
+		)
+		r.Host = c.host
+		if c.sig != "" {
+			r.Header.Set("X-Signature", c.sig)
+		}
+		c.h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+}
+
+func echo[T any](ctx context.Context, v T) (*T, error) {
+	return &v, nil
+}
+
+type tenantReq struct {
+	Tenant string   `header:"X-Tenant-ID" jh:"required"`
+	Trace  *int     `header:"X-Trace"`
+	Langs  []string `header:"Accept-Language"`
+	Limit  int      `header:"X-Limit" query:"limit" json:"limit"`
+}
+
+func TestBindHeader(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, req tenantReq) (*tenantReq, error) {
+		return &req, nil
+	}, ErrHandler)
+
+	cases := []struct {
+		target, body string
+		header       http.Header
+		wantStatus   int
+		wantBody     string
+	}{
+		{
+			"/", `{}`,
+			http.Header{"X-Tenant-Id": {"acme"}, "X-Trace": {"7"}, "Accept-Language": {"en", "fr"}},
+			200, `{"Tenant":"acme","Trace":7,"Langs":["en","fr"],"limit":0}`,
+		},
+		{
+			"/", `{"limit":1}`,
+			http.Header{"X-Tenant-Id": {"acme"}, "X-Limit": {"2"}},
+			200, `{"Tenant":"acme","Trace":null,"Langs":null,"limit":2}`,
+		},
+		{
+			"/?limit=3", `{"limit":1}`,
+			http.Header{"X-Tenant-Id": {"acme"}, "X-Limit": {"2"}},
+			200, `{"Tenant":"acme","Trace":null,"Langs":null,"limit":3}`,
+		},
+		{
+			"/", `{}`,
+			http.Header{},
+			400, `{"message":"missing header \"X-Tenant-ID\""}`,
+		},
+		{
+			"/", `{}`,
+			http.Header{"X-Tenant-Id": {"acme"}, "X-Trace": {"x"}},
+			400, `{"message":"invalid header \"X-Trace\": \"x\" is not an int"}`,
+		},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		for k, v := range c.header {
+			r.Header[k] = v
+		}
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+}
+
+type session struct {
+	Token string `cookie:"session" jh:"required"`
+	Theme string `cookie:"theme"`
+	Seen  []int  `cookie:"seen"`
+}
+
+func TestBindCookie(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, s session) (*session, error) {
+		return &s, nil
+	}, ErrHandler)
+
+	cases := []struct {
+		cookie     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"session=abc; theme=dark; seen=1; seen=2", 200, `{"Token":"abc","Theme":"dark","Seen":[1,2]}`},
+		{"session=abc", 200, `{"Token":"abc","Theme":"","Seen":null}`},
+		{"theme=dark", 400, `{"message":"missing cookie \"session\""}`},
+		{"session=abc; seen=x", 400, `{"message":"invalid cookie \"seen\": \"x\" is not an int"}`},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Cookie", c.cookie)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.cookie, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.cookie, got, c.wantBody)
+		}
+	}
+}
+
+type upload struct {
+	Title string                  `form:"title"`
+	Draft bool                    `form:"draft"`
+	File  *multipart.FileHeader   `file:"file"`
+	Extra []*multipart.FileHeader `file:"extra"`
+}
+
+func TestBindMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "notes")
+	mw.WriteField("draft", "true")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello"))
+	mw.CreateFormFile("extra", "b.txt")
+	mw.CreateFormFile("extra", "c.txt")
+	mw.Close()
+
+	type result struct {
+		Title    string
+		Draft    bool
+		Contents string
+		Extra    int
+	}
+	h, _ := Handler(func(ctx context.Context, u upload) (*result, error) {
+		f, err := u.File.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		b, _ := io.ReadAll(f)
+		return &result{u.Title, u.Draft, string(b), len(u.Extra)}, nil
+	}, ErrHandler)
+
+	var (
+		r   = httptest.NewRequest("POST", "/", &body)
+		rec = httptest.NewRecorder()
+	)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ServeHTTP(rec, r)
+	got, _ := io.ReadAll(rec.Result().Body)
+	want := `{"Title":"notes","Draft":true,"Contents":"hello","Extra":2}`
+	if strings.TrimSpace(string(got)) != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+
+	var (
+		bad    = httptest.NewRequest("POST", "/", strings.NewReader("garbage"))
+		badrec = httptest.NewRecorder()
+	)
+	bad.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	h.ServeHTTP(badrec, bad)
+	if badrec.Code != 400 {
+		t.Errorf("got %d want %d", badrec.Code, 400)
+	}
+}
+
+type contactForm struct {
+	Email string        `form:"email" jh:"required"`
+	Age   int           `form:"age"`
+	Tags  []string      `form:"tag"`
+	Wait  time.Duration `form:"wait"`
+	Ref   string        `query:"ref"`
+}
+
+func TestBindForm(t *testing.T) {
+	h, _ := Handler(echo[contactForm], ErrHandler)
+	cases := []struct {
+		target, body string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"/", "email=a%40b.c&age=30&tag=x&tag=y&wait=5s", 200, `{"Email":"a@b.c","Age":30,"Tags":["x","y"],"Wait":5000000000,"Ref":""}`},
+		{"/?ref=ad&email=q", "email=a%40b.c", 200, `{"Email":"a@b.c","Age":0,"Tags":null,"Wait":0,"Ref":"ad"}`},
+		{"/", "email=a%40b.c&age=old", 400, ""},
+		{"/", "age=30", 400, ""},
+		{"/", "email=%zz", 400, ""},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+			continue
+		}
+		if got := strings.TrimSpace(rec.Body.String()); c.wantBody != "" && got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.body, got, c.wantBody)
+		}
+	}
+}
+
+func TestBindUnsupported(t *testing.T) {
+	type (
+		badQuery struct {
+			Filter map[string]string `query:"filter"`
+		}
+		badHeader struct {
+			IDs []*int `header:"X-ID"`
+		}
+		badFile struct {
+			Upload string `file:"upload"`
+		}
+		ok struct {
+			Limit *int      `query:"limit"`
+			Tags  []string  `query:"tag"`
+			Since time.Time `header:"X-Since"`
+			Skip  chan int  `query:"-"`
+		}
+	)
+	cases := []struct {
+		f    any
+		want string
+	}{
+		{func(ctx context.Context, r badQuery) error { return nil }, "jh: query parameter field Filter: unsupported type map[string]string"},
+		{func(ctx context.Context, r badHeader) error { return nil }, "jh: header field IDs: unsupported type []*int"},
+		{func(ctx context.Context, r badFile) error { return nil }, "jh: file field Upload: unsupported type string"},
+		{func(ctx context.Context, r ok) error { return nil }, ""},
+	}
+	for _, c := range cases {
+		_, err := Handler(c.f, ErrHandler)
+		if got := fmt.Sprint(err); c.want != "" && got != c.want || c.want == "" && err != nil {
+			t.Errorf("%T: got %v want %q", c.f, err, c.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("HandlerFunc didn't panic")
+		}
+	}()
+	HandlerFunc(func(ctx context.Context, r badQuery) (*badQuery, error) { return &r, nil })
+}
@@ -0,0 +1,33 @@
+package jh
+
+import (
+	"bytes"
+	"sync"
+)
+
+var bodyBuffers = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer for reading a request
+// body, from bodyBuffers when the [ReuseBuffers] option is used.
+func (h *handler) getBuffer(reuse bool) *bytes.Buffer {
+	if !reuse || h.maxBufferSize <= 0 {
+		return new(bytes.Buffer)
+	}
+	return bodyBuffers.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns b to bodyBuffers unless it has grown past
+// the handler's limit. Its contents are zeroed first so no
+// request's data outlives the request.
+func (h *handler) putBuffer(b *bytes.Buffer, reuse bool) {
+	if b == nil || !reuse || h.maxBufferSize <= 0 || b.Cap() > h.maxBufferSize {
+		return
+	}
+	clear(b.Bytes())
+	b.Reset()
+	bodyBuffers.Put(b)
+}
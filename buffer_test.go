@@ -0,0 +1,77 @@
+package jh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bufferedUpload struct {
+	Name string `json:"name" jh:"required"`
+	Data string `json:"data"`
+}
+
+func TestReuseBuffers(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, u bufferedUpload) (*bufferedUpload, error) {
+		return &u, nil
+	}, ReuseBuffers(1<<10))
+
+	for _, name := range []string{"first", "second"} {
+		var (
+			body = fmt.Sprintf(`{"name":%q,"data":"secret-%s"}`, name, name)
+			r    = httptest.NewRequest("POST", "/", strings.NewReader(body))
+			rec  = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if got := strings.TrimSpace(rec.Body.String()); got != body {
+			t.Errorf("got %s want %s", got, body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"data":"x"}`)))
+	if rec.Code != 400 {
+		t.Errorf("got %d want 400", rec.Code)
+	}
+
+	var (
+		hh  = &handler{maxBufferSize: 1 << 10}
+		buf = hh.getBuffer(true)
+	)
+	buf.WriteString("secret")
+	data := buf.Bytes()
+	hh.putBuffer(buf, true)
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Errorf("buffer kept %q", data)
+	}
+}
+
+func BenchmarkReuseBuffers(b *testing.B) {
+	body := []byte(fmt.Sprintf(`{"name":"a","data":%q}`, strings.Repeat("x", 16<<10)))
+	echo := func(ctx context.Context, u bufferedUpload) (*struct{}, error) {
+		return nil, nil
+	}
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{"New", nil},
+		{"Reused", []Option{ReuseBuffers(64 << 10)}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			h := HandlerFunc(echo, bm.opts...)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			r := httptest.NewRequest("POST", "/", nil)
+			for i := 0; i < b.N; i++ {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
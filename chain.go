@@ -0,0 +1,18 @@
+package jh
+
+import "net/http"
+
+// Chain wraps h with middleware. The first middleware is the
+// outermost so it sees requests first and responses last.
+//
+//	h := Chain(HandlerFunc(add), RequestIDHandler, CORS(opts), Gzip)
+//
+// is the same as
+//
+//	h := RequestIDHandler(CORS(opts)(Gzip(HandlerFunc(add))))
+func Chain(h http.Handler, mw ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
@@ -0,0 +1,46 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+" in")
+				next.ServeHTTP(w, r)
+				order = append(order, name+" out")
+			})
+		}
+	}
+	inner, _ := Handler(func(ctx context.Context) (*struct{}, error) {
+		order = append(order, "handler")
+		return &struct{}{}, nil
+	}, ErrHandler)
+	h := Chain(inner, trace("a"), trace("b"), RequestIDHandler)
+
+	var (
+		r   = httptest.NewRequest("GET", "/", nil)
+		rec = httptest.NewRecorder()
+	)
+	h.ServeHTTP(rec, r)
+	var (
+		got  = strings.Join(order, ", ")
+		want = "a in, b in, handler, b out, a out"
+	)
+	if got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if rec.Header().Get(RequestIDHeader) == "" {
+		t.Errorf("missing %s", RequestIDHeader)
+	}
+	if Chain(inner) != inner {
+		t.Error("Chain without middleware should return h")
+	}
+}
@@ -0,0 +1,330 @@
+package jh
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An Encoder writes the encoding of v to w.
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+// A Decoder reads an encoded value from r and stores it in v.
+type Decoder interface {
+	Decode(r io.Reader, v any) error
+}
+
+// The EncoderFunc type is an adapter to allow the use of
+// ordinary functions as Encoders.
+type EncoderFunc func(w io.Writer, v any) error
+
+func (f EncoderFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+// The DecoderFunc type is an adapter to allow the use of
+// ordinary functions as Decoders.
+type DecoderFunc func(r io.Reader, v any) error
+
+func (f DecoderFunc) Decode(r io.Reader, v any) error {
+	return f(r, v)
+}
+
+type codec struct {
+	contentType string
+	enc         Encoder
+	dec         Decoder
+}
+
+// jsonCodec uses encoding/json. Handlers replace the
+// registered jsonCodec with their own configured copy.
+type jsonCodec struct {
+	disallowUnknownFields bool
+	disallowTrailingData  bool
+	useNumber             bool
+	noEscapeHTML          bool
+	prefix, indent        string
+}
+
+func (c jsonCodec) Encode(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(!c.noEscapeHTML)
+	if c.prefix != "" || c.indent != "" {
+		enc.SetIndent(c.prefix, c.indent)
+	}
+	return enc.Encode(v)
+}
+
+func (c jsonCodec) Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if c.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if c.useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if c.disallowTrailingData {
+		if _, err := dec.Token(); err != io.EOF {
+			return errTrailingData
+		}
+	}
+	return nil
+}
+
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+var (
+	stdJSON = codec{
+		contentType: "application/json; charset=utf-8",
+		enc:         jsonCodec{},
+		dec:         jsonCodec{},
+	}
+	stdXML = codec{
+		contentType: "application/xml; charset=utf-8",
+		enc: EncoderFunc(func(w io.Writer, v any) error {
+			return xml.NewEncoder(w).Encode(v)
+		}),
+		dec: DecoderFunc(func(r io.Reader, v any) error {
+			return xml.NewDecoder(r).Decode(v)
+		}),
+	}
+)
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]codec
+}{
+	m: map[string]codec{
+		"application/json": stdJSON,
+		"application/xml":  stdXML,
+	},
+}
+
+// RegisterCodec makes a format available to all handlers.
+// Requests whose Content-Type matches contentType are decoded
+// using dec and responses are encoded using enc when the
+// request's Accept header prefers contentType.
+// JSON and XML are registered by default.
+// JSON is used when no registered format matches.
+func RegisterCodec(contentType string, enc Encoder, dec Decoder) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.m[mediaType(contentType)] = codec{
+		contentType: contentType,
+		enc:         enc,
+		dec:         dec,
+	}
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// typeErrorMessage describes a JSON value of the wrong type using
+// JSON's type names, eg `field "x" must be an integer, not a string`.
+func typeErrorMessage(e *json.UnmarshalTypeError) string {
+	kind, _, _ := strings.Cut(e.Value, " ") // eg "number 1.5"
+	got, ok := map[string]string{
+		"string": "a string",
+		"number": "a number",
+		"bool":   "a boolean",
+		"array":  "an array",
+		"object": "an object",
+	}[kind]
+	if !ok {
+		got = e.Value
+	}
+	msg := fmt.Sprintf("must be %s, not %s", jsonTypeName(e.Type), got)
+	if e.Field == "" {
+		return "request body " + msg
+	}
+	return fmt.Sprintf("field %q %s", e.Field, msg)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonTypeName returns the JSON type that
+// encoding/json decodes into t with an article.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "a base64 string"
+		}
+		return "an array"
+	case reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
+
+// isJSON reports whether c encodes JSON.
+func isJSON(c codec) bool {
+	mt := mediaType(c.contentType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// lookupCodec returns the codec for the media type mt. Codecs
+// added with the [Codec] option take precedence over registered ones.
+func (h *handler) lookupCodec(mt string) (codec, bool) {
+	if c, ok := h.codecs[mt]; ok {
+		return c, true
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.m[mt]
+	return h.codec(c), ok
+}
+
+// supportedContentType reports whether request bodies
+// of contentType can be decoded without falling back to JSON.
+func (h *handler) supportedContentType(contentType string) bool {
+	mt := mediaType(contentType)
+	if mt == "application/x-www-form-urlencoded" || mt == "multipart/form-data" {
+		return true
+	}
+	_, ok := h.lookupCodec(mt)
+	return ok
+}
+
+// requestCodec returns the codec for
+// decoding a body of the given Content-Type.
+func (h *handler) requestCodec(contentType string) codec {
+	if c, ok := h.lookupCodec(mediaType(contentType)); ok {
+		return c
+	}
+	c, _ := h.lookupCodec("application/json")
+	return c
+}
+
+// responseCodec returns the codec that the
+// given Accept header prefers.
+func (h *handler) responseCodec(accept string) codec {
+	for _, mt := range acceptable(accept) {
+		if mt == mediaType(h.contentType) {
+			break
+		}
+		if c, ok := h.lookupCodec(mt); ok {
+			return h.withContentType(c)
+		}
+		if mt == "*/*" || mt == "application/*" {
+			break
+		}
+	}
+	c, _ := h.lookupCodec("application/json")
+	return h.withContentType(c)
+}
+
+// withContentType applies the [ContentType] option to c.
+func (h *handler) withContentType(c codec) codec {
+	if _, ok := c.enc.(jsonCodec); ok && h.contentType != "" {
+		c.contentType = h.contentType
+	}
+	return c
+}
+
+// A Marshaler is a response that encodes itself in the formats
+// it supports, eg CSV and JSON for an export endpoint. contentType
+// is the media range that the request's Accept header prefers,
+// like "text/csv", "text/*" or "*/*" when there's no header.
+// MarshalTo returns the encoded response and its Content-Type.
+// An error, eg an [Error] with a 406 Code, is passed to the
+// error func instead.
+type Marshaler interface {
+	MarshalTo(contentType string) ([]byte, string, error)
+}
+
+// respondMarshaler writes the response encoded by m.
+func (h *handler) respondMarshaler(ctx context.Context, w http.ResponseWriter, r *http.Request, st *state, status int, m Marshaler) {
+	mt := "*/*"
+	if mts := acceptable(r.Header.Get("Accept")); len(mts) > 0 {
+		mt = mts[0]
+	}
+	b, contentType, err := m.MarshalTo(mt)
+	if err != nil {
+		st.err = err
+		h.ef(ctx, w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if _, err := w.Write(b); err != nil {
+		st.err = fmt.Errorf("jh: writing response: %w", err)
+	}
+}
+
+// acceptable returns the media ranges of an Accept header
+// ordered by preference. Ranges with q=0 are omitted.
+func acceptable(accept string) []string {
+	if accept == "" {
+		return nil
+	}
+	type mediaRange struct {
+		mt string
+		q  float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{mt, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	mts := make([]string, len(ranges))
+	for i := range ranges {
+		mts[i] = ranges[i].mt
+	}
+	return mts
+}
@@ -0,0 +1,251 @@
+package jh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X int `json:"x" xml:"x"`
+	Y int `json:"y" xml:"y"`
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	identity := func(ctx context.Context, p point) (*point, error) {
+		return &p, nil
+	}
+	h, _ := Handler(identity, ErrHandler)
+
+	cases := []struct {
+		contentType, accept, body string
+		wantType, wantBody        string
+	}{
+		{
+			"", "",
+			`{"x":1,"y":2}`,
+			"application/json; charset=utf-8", "{\"x\":1,\"y\":2}\n",
+		},
+		{
+			"application/json", "application/xml",
+			`{"x":1,"y":2}`,
+			"application/xml; charset=utf-8", "<point><x>1</x><y>2</y></point>",
+		},
+		{
+			"application/xml", "text/html, application/json;q=0.5, application/xml;q=0.9",
+			`<point><x>1</x><y>2</y></point>`,
+			"application/xml; charset=utf-8", "<point><x>1</x><y>2</y></point>",
+		},
+		{
+			"application/xml; charset=utf-8", "*/*",
+			`<point><x>1</x><y>2</y></point>`,
+			"application/json; charset=utf-8", "{\"x\":1,\"y\":2}\n",
+		},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Content-Type", c.contentType)
+		r.Header.Set("Accept", c.accept)
+		h.ServeHTTP(rec, r)
+
+		got, _ := io.ReadAll(rec.Result().Body)
+		if string(got) != c.wantBody {
+			t.Errorf("%q: got = %q; want %q", c.accept, got, c.wantBody)
+		}
+		if ct := rec.Result().Header.Get("Content-Type"); ct != c.wantType {
+			t.Errorf("%q: got = %q; want %q", c.accept, ct, c.wantType)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("text/plain",
+		EncoderFunc(func(w io.Writer, v any) error {
+			_, err := fmt.Fprintf(w, "%d,%d", v.(*point).X, v.(*point).Y)
+			return err
+		}),
+		DecoderFunc(func(r io.Reader, v any) error {
+			_, err := fmt.Fscanf(r, "%d,%d", &v.(*point).X, &v.(*point).Y)
+			return err
+		}),
+	)
+	defer func() {
+		codecs.Lock()
+		delete(codecs.m, "text/plain")
+		codecs.Unlock()
+	}()
+
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader("1,2"))
+		rec = httptest.NewRecorder()
+	)
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("Accept", "text/plain")
+	h, _ := Handler(func(ctx context.Context, p point) (*point, error) {
+		return &point{p.Y, p.X}, nil
+	}, ErrHandler)
+	h.ServeHTTP(rec, r)
+
+	got, _ := io.ReadAll(rec.Result().Body)
+	if string(got) != "2,1" {
+		t.Errorf("got = %q; want %q", got, "2,1")
+	}
+}
+
+func TestAcceptable(t *testing.T) {
+	got := acceptable("text/*;q=0.3, text/html;q=0.7, application/xml;q=0, text/plain, */*;q=0.5")
+	want := []string{"text/plain", "text/html", "*/*", "text/*"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestCodecOption(t *testing.T) {
+	var (
+		encoded bool
+		jsonEnc = EncoderFunc(func(w io.Writer, v any) error {
+			encoded = true
+			return json.NewEncoder(w).Encode(v)
+		})
+		jsonDec = DecoderFunc(func(r io.Reader, v any) error {
+			return json.NewDecoder(r).Decode(v)
+		})
+		csvEnc = EncoderFunc(func(w io.Writer, v any) error {
+			_, err := fmt.Fprintf(w, "%d,%d", v.(*point).X, v.(*point).Y)
+			return err
+		})
+		csvDec = DecoderFunc(func(r io.Reader, v any) error {
+			_, err := fmt.Fscanf(r, "%d,%d", &v.(*point).X, &v.(*point).Y)
+			return err
+		})
+	)
+	h, _ := Handler(echoPoint, ErrHandler,
+		Codec("application/json", jsonEnc, jsonDec),
+		Codec("text/csv", csvEnc, csvDec),
+		RequireContentType(true),
+	)
+	cases := []struct {
+		contentType, accept, body string
+		wantStatus                int
+		wantBody                  string
+	}{
+		{"application/json", "", `{"x":1,"y":2}`, 200, "{\"x\":1,\"y\":2}\n"},
+		{"text/csv", "text/csv", "3,4", 200, "3,4"},
+		{"text/csv", "application/xml", "3,4", 200, "<point><x>3</x><y>4</y></point>"},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Content-Type", c.contentType)
+		r.Header.Set("Accept", c.accept)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := rec.Body.String(); got != c.wantBody {
+			t.Errorf("case %d: got = %q; want %q", i, got, c.wantBody)
+		}
+	}
+	if !encoded {
+		t.Error("Codec didn't replace encoding/json")
+	}
+
+	// other handlers keep the registered codecs
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader("3,4"))
+		rec = httptest.NewRecorder()
+	)
+	r.Header.Set("Content-Type", "text/csv")
+	HandlerFunc(echoPoint).ServeHTTP(rec, r)
+	if rec.Code != 400 {
+		t.Errorf("got %d want %d", rec.Code, 400)
+	}
+}
+
+type report []point
+
+func (rp report) MarshalTo(contentType string) ([]byte, string, error) {
+	switch contentType {
+	case "text/csv", "text/*":
+		var b strings.Builder
+		b.WriteString("x,y\n")
+		for _, p := range rp {
+			fmt.Fprintf(&b, "%d,%d\n", p.X, p.Y)
+		}
+		return []byte(b.String()), "text/csv", nil
+	case "application/json", "*/*":
+		b, err := json.Marshal([]point(rp))
+		return b, "application/json", err
+	}
+	return nil, "", Errorf(406, "can't produce %s", contentType)
+}
+
+func TestMarshaler(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context) (report, error) {
+		return report{{1, 2}}, nil
+	}, ErrHandler)
+	cases := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", 200, "application/json", `[{"x":1,"y":2}]`},
+		{"text/csv", 200, "text/csv", "x,y\n1,2\n"},
+		{"application/json;q=0.5, text/*", 200, "text/csv", "x,y\n1,2\n"},
+		{"image/png", 406, "application/json; charset=utf-8", "{\"message\":\"can't produce image/png\"}\n"},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Accept", c.accept)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.status {
+			t.Errorf("%q: got %d want %d", c.accept, rec.Code, c.status)
+		}
+		if got := rec.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("%q: got %q want %q", c.accept, got, c.contentType)
+		}
+		if got := rec.Body.String(); got != c.body {
+			t.Errorf("%q: got %q want %q", c.accept, got, c.body)
+		}
+	}
+}
+
+func TestCodecEncodeError(t *testing.T) {
+	ef := func(ctx context.Context, w http.ResponseWriter, err error) {
+		w.WriteHeader(500)
+		io.WriteString(w, "oops")
+	}
+	resp := func(ctx context.Context) (*map[string]any, error) {
+		return &map[string]any{"x": 1}, nil
+	}
+	for i, opts := range [][]Option{nil, {BufferResponse(true)}, {ETags(true)}} {
+		h, _ := Handler(resp, ef, opts...)
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Accept", "application/xml")
+		h.ServeHTTP(rec, r)
+		if rec.Code != 500 {
+			t.Errorf("case %d: got %d want 500", i, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "" {
+			t.Errorf("case %d: got Content-Type %q", i, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package jh
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// An Encoding is a content coding that [Compress] can use.
+type Encoding struct {
+	// Name is the coding's name in Accept-Encoding
+	// and Content-Encoding headers, eg "br".
+	Name string
+
+	// NewWriter returns a writer compressing to w.
+	// Closing it must flush the compressed data
+	// but not close w.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+var (
+	// GzipEncoding is the gzip content coding.
+	GzipEncoding = Encoding{
+		Name: "gzip",
+		NewWriter: func(w io.Writer) io.WriteCloser {
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(w)
+			return pooledGzip{gz}
+		},
+	}
+
+	// DeflateEncoding is the deflate content coding,
+	// which is zlib compressed data.
+	DeflateEncoding = Encoding{
+		Name: "deflate",
+		NewWriter: func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+	}
+)
+
+// CompressOptions configures [Compress].
+type CompressOptions struct {
+	// Encodings are the codings that responses can be compressed
+	// with, in order of preference for when a request accepts
+	// several equally. The default is [GzipEncoding]. Brotli
+	// can be added with an Encoding named "br" whose NewWriter
+	// comes from a brotli package.
+	Encodings []Encoding
+
+	// MinSize is the smallest response body, in bytes,
+	// that is compressed. The default is [GzipMinSize].
+	MinSize int
+}
+
+// Compress returns middleware that compresses responses with the
+// encoding that the request's Accept-Encoding header prefers,
+// taking quality values into account. Bodies smaller than
+// MinSize and responses that already have a Content-Encoding
+// are sent as is.
+//
+//	br := jh.Encoding{Name: "br", NewWriter: func(w io.Writer) io.WriteCloser {
+//		return brotli.NewWriter(w)
+//	}}
+//	h = jh.Compress(jh.CompressOptions{
+//		Encodings: []jh.Encoding{br, jh.GzipEncoding, jh.DeflateEncoding},
+//	})(h)
+func Compress(opts CompressOptions) func(http.Handler) http.Handler {
+	encodings := opts.Encodings
+	if len(encodings) == 0 {
+		encodings = []Encoding{GzipEncoding}
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			minSize := opts.MinSize
+			if minSize <= 0 {
+				minSize = GzipMinSize
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				enc:            enc,
+				minSize:        minSize,
+				status:         http.StatusOK,
+			}
+			defer cw.close()
+			h.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the encoding with the highest quality
+// in acceptEncoding. Ties go to the one listed first in encodings.
+func negotiateEncoding(acceptEncoding string, encodings []Encoding) (Encoding, bool) {
+	var (
+		quality  = map[string]float64{}
+		wildcard = -1.0
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		if _, v, ok := strings.Cut(params, "q="); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			q = n
+		}
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		quality[coding] = q
+	}
+
+	var (
+		best  Encoding
+		bestQ float64
+	)
+	for _, enc := range encodings {
+		q, ok := quality[enc.Name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
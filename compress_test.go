@@ -0,0 +1,68 @@
+package jh
+
+import (
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	all := []Encoding{{Name: "br"}, GzipEncoding, DeflateEncoding}
+	cases := []struct {
+		accept    string
+		encodings []Encoding
+		want      string
+	}{
+		{"gzip, deflate, br", all, "br"},
+		{"gzip;q=1.0, br;q=0.5", all, "gzip"},
+		{"deflate;q=0.8, gzip;q=0.4", all, "deflate"},
+		{"br;q=0, *", all, "gzip"},
+		{"*;q=0", all, ""},
+		{"identity", all, ""},
+		{"", all, ""},
+		{"GZIP", all, "gzip"},
+		{"br, deflate", []Encoding{GzipEncoding}, ""},
+		{"gzip;q=x, deflate", all, "deflate"},
+	}
+	for _, c := range cases {
+		var got string
+		if enc, ok := negotiateEncoding(c.accept, c.encodings); ok {
+			got = enc.Name
+		}
+		if got != c.want {
+			t.Errorf("%q: got %q want %q", c.accept, got, c.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	h := Compress(CompressOptions{
+		Encodings: []Encoding{GzipEncoding, DeflateEncoding},
+		MinSize:   10,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	var (
+		r   = httptest.NewRequest("GET", "/", nil)
+		rec = httptest.NewRecorder()
+	)
+	r.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate")
+	h.ServeHTTP(rec, r)
+	if got := rec.Header().Get("Content-Encoding"); got != "deflate" {
+		t.Fatalf("got %q want deflate", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("got Vary %q", got)
+	}
+	zr, err := zlib.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("got %q", got)
+	}
+}
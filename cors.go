@@ -0,0 +1,119 @@
+package jh
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures [CORS].
+type CORSOptions struct {
+	// AllowedOrigins lists the origins that may make
+	// cross-origin requests. "*" allows every origin.
+	AllowedOrigins []string
+
+	// AllowOrigin, when set, is used instead of
+	// AllowedOrigins to decide if an origin is allowed.
+	AllowOrigin func(r *http.Request, origin string) bool
+
+	// AllowedMethods lists the methods allowed in
+	// preflight responses. The default is GET, HEAD and POST.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in
+	// preflight responses. "*" allows whatever headers
+	// the preflight request asks for.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers that
+	// browsers let scripts read.
+	ExposedHeaders []string
+
+	// AllowCredentials lets requests include cookies and
+	// authorization. The allowed origin is echoed instead
+	// of sending "*" since browsers reject the wildcard
+	// for credentialed requests.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache
+	// preflight responses. Zero omits the header.
+	MaxAge time.Duration
+}
+
+// CORS returns middleware that adds the Access-Control-* headers
+// to responses for allowed origins. Preflight requests are
+// answered with a 204 and don't reach the wrapped handler.
+//
+//	h := CORS(CORSOptions{AllowedOrigins: []string{"*"}})(HandlerFunc(add))
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	var (
+		allowMethods  = strings.Join(methods, ", ")
+		allowHeaders  = strings.Join(opts.AllowedHeaders, ", ")
+		exposeHeaders = strings.Join(opts.ExposedHeaders, ", ")
+		anyHeader     = slices.Contains(opts.AllowedHeaders, "*")
+		anyOrigin     = slices.Contains(opts.AllowedOrigins, "*")
+	)
+	allowed := func(r *http.Request, origin string) bool {
+		if opts.AllowOrigin != nil {
+			return opts.AllowOrigin(r, origin)
+		}
+		return anyOrigin || slices.Contains(opts.AllowedOrigins, origin)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hdr := w.Header()
+			hdr.Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions &&
+				r.Header.Get("Access-Control-Request-Method") != ""
+
+			if origin == "" || !allowed(r, origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin && opts.AllowOrigin == nil && !opts.AllowCredentials {
+				hdr.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				hdr.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				hdr.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					hdr.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hdr.Add("Vary", "Access-Control-Request-Method")
+			hdr.Add("Vary", "Access-Control-Request-Headers")
+			hdr.Set("Access-Control-Allow-Methods", allowMethods)
+			if anyHeader {
+				if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+					hdr.Set("Access-Control-Allow-Headers", req)
+				}
+			} else if allowHeaders != "" {
+				hdr.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if opts.MaxAge > 0 {
+				hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	var called bool
+	h := HandlerFunc(func(ctx context.Context, _ struct{}) (*struct{}, error) {
+		called = true
+		return &struct{}{}, nil
+	})
+
+	cases := []struct {
+		name    string
+		opts    CORSOptions
+		method  string
+		headers map[string]string
+
+		wantCalled bool
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "no origin",
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}},
+			method:     "GET",
+			wantCalled: true,
+			wantStatus: 200,
+			want:       map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "wildcard",
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Total-Count"}},
+			method:     "GET",
+			headers:    map[string]string{"Origin": "https://a.example"},
+			wantCalled: true,
+			wantStatus: 200,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "X-Total-Count",
+			},
+		},
+		{
+			name:       "credentials echo origin",
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:     "GET",
+			headers:    map[string]string{"Origin": "https://a.example"},
+			wantCalled: true,
+			wantStatus: 200,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://a.example",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:       "disallowed origin",
+			opts:       CORSOptions{AllowedOrigins: []string{"https://a.example"}},
+			method:     "GET",
+			headers:    map[string]string{"Origin": "https://b.example"},
+			wantCalled: true,
+			wantStatus: 200,
+			want:       map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "callback",
+			opts: CORSOptions{AllowOrigin: func(r *http.Request, origin string) bool {
+				return strings.HasSuffix(origin, ".example")
+			}},
+			method:     "GET",
+			headers:    map[string]string{"Origin": "https://b.example"},
+			wantCalled: true,
+			wantStatus: 200,
+			want:       map[string]string{"Access-Control-Allow-Origin": "https://b.example"},
+		},
+		{
+			name: "preflight",
+			opts: CORSOptions{
+				AllowedOrigins: []string{"https://a.example"},
+				AllowedMethods: []string{"PUT", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "Authorization"},
+				MaxAge:         10 * time.Minute,
+			},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://a.example",
+				"Access-Control-Request-Method": "PUT",
+			},
+			wantStatus: 204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "https://a.example",
+				"Access-Control-Allow-Methods": "PUT, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:   "preflight any header",
+			opts:   CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://a.example",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "X-Custom",
+			},
+			wantStatus: 204,
+			want: map[string]string{
+				"Access-Control-Allow-Methods": "GET, HEAD, POST",
+				"Access-Control-Allow-Headers": "X-Custom",
+			},
+		},
+	}
+	for _, c := range cases {
+		called = false
+		var (
+			r   = httptest.NewRequest(c.method, "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		for k, v := range c.headers {
+			r.Header.Set(k, v)
+		}
+		CORS(c.opts)(h).ServeHTTP(rec, r)
+		if called != c.wantCalled {
+			t.Errorf("%s: called = %t", c.name, called)
+		}
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.name, rec.Code, c.wantStatus)
+		}
+		for k, want := range c.want {
+			if got := rec.Header().Get(k); got != want {
+				t.Errorf("%s: %s = %q; want %q", c.name, k, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// A Drainer tracks the requests being served by the handlers it
+// wraps so a server can wait for them to finish before exiting.
+// The zero value is ready to use.
+//
+//	var d jh.Drainer
+//	srv := &http.Server{Handler: d.Track(mux)}
+//	...
+//	srv.Shutdown(ctx)
+//	d.Drain(ctx)
+//
+// [http.Server.Shutdown] already waits for idle connections, but
+// not for handlers of hijacked connections or handlers that keep
+// running after their connection is closed, eg ones doing work in
+// the background. Drain waits for those too.
+type Drainer struct {
+	mu       sync.Mutex
+	inFlight int
+	idle     chan struct{}
+}
+
+// Track returns a handler that serves requests using
+// h and counts them as in flight until h returns.
+func (d *Drainer) Track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.inFlight++
+		d.mu.Unlock()
+		defer d.done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (d *Drainer) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight--
+	if d.inFlight == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// InFlight returns the number of requests being served.
+func (d *Drainer) InFlight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inFlight
+}
+
+// Drain waits until no requests are in flight or
+// ctx is done, in which case it returns ctx's error.
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	if d.inFlight == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
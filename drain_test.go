@@ -0,0 +1,60 @@
+package jh
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainer(t *testing.T) {
+	var (
+		d        Drainer
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		finished = make(chan int)
+	)
+	slow, _ := Handler(func(ctx context.Context) (*struct{}, error) {
+		started <- struct{}{}
+		<-release
+		return &struct{}{}, nil
+	}, ErrHandler)
+	h := d.Track(slow)
+
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatalf("got %v draining without requests", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			finished <- rec.Code
+		}()
+		<-started
+	}
+	if n := d.InFlight(); n != 2 {
+		t.Errorf("got %d in flight want 2", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v want %v", err, context.DeadlineExceeded)
+	}
+
+	drained := make(chan error)
+	go func() { drained <- d.Drain(context.Background()) }()
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-finished; code != 200 {
+			t.Errorf("got %d want 200", code)
+		}
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("got %v", err)
+	}
+	if n := d.InFlight(); n != 0 {
+		t.Errorf("got %d in flight want 0", n)
+	}
+}
@@ -0,0 +1,79 @@
+package jh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Response types can implement ETagger to supply the ETag
+// used by the [ETags] option instead of hashing the encoded
+// response. The tag is quoted if it isn't already.
+type ETagger interface {
+	ETag() string
+}
+
+// respondETag writes body, the possibly enveloped resp, with an ETag
+// header, or a 304 Not Modified without a body when the request's
+// If-None-Match header matches.
+func (h *handler) respondETag(ctx context.Context, w http.ResponseWriter, r *http.Request, st *state, c codec, resp, body any) {
+	var tag string
+	if et, ok := resp.(ETagger); ok {
+		tag = quoteETag(et.ETag())
+		if etagMatch(r.Header.Get("If-None-Match"), tag) {
+			notModified(w, tag)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.enc.Encode(&buf, body); err != nil {
+		h.encodeError(ctx, w, st, err)
+		return
+	}
+	if tag == "" {
+		sum := sha256.Sum256(buf.Bytes())
+		tag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		if etagMatch(r.Header.Get("If-None-Match"), tag) {
+			notModified(w, tag)
+			return
+		}
+	}
+	w.Header().Set("ETag", tag)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		st.err = fmt.Errorf("jh: writing response: %w", err)
+	}
+}
+
+func notModified(w http.ResponseWriter, tag string) {
+	hdr := w.Header()
+	hdr.Set("ETag", tag)
+	hdr.Del("Content-Type")
+	hdr.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatch reports whether an If-None-Match header matches
+// tag using the weak comparison required for GET and HEAD.
+func etagMatch(ifNoneMatch, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
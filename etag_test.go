@@ -0,0 +1,75 @@
+package jh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type versioned struct {
+	Name string `json:"name"`
+	Rev  int    `json:"-"`
+}
+
+func (v versioned) ETag() string {
+	return fmt.Sprintf("v%d", v.Rev)
+}
+
+func TestETags(t *testing.T) {
+	var (
+		hashed, _ = Handler(func(ctx context.Context) (*point, error) {
+			return &point{1, 2}, nil
+		}, ErrHandler, ETags(true))
+		custom, _ = Handler(func(ctx context.Context) (versioned, error) {
+			return versioned{"a", 3}, nil
+		}, ErrHandler, ETags(true))
+	)
+
+	rec := httptest.NewRecorder()
+	hashed.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	tag := rec.Header().Get("ETag")
+	if rec.Code != 200 || tag == "" || tag[0] != '"' {
+		t.Fatalf("got %d with ETag %q", rec.Code, tag)
+	}
+	if got := rec.Body.String(); got != "{\"x\":1,\"y\":2}\n" {
+		t.Errorf("got = %q", got)
+	}
+
+	cases := []struct {
+		h           http.Handler
+		method, inm string
+		want        int
+		wantETag    string
+	}{
+		{hashed, "GET", tag, 304, tag},
+		{hashed, "GET", `"other", W/` + tag, 304, tag},
+		{hashed, "GET", "*", 304, tag},
+		{hashed, "GET", `"other"`, 200, tag},
+		{hashed, "HEAD", tag, 304, tag},
+		{hashed, "POST", tag, 200, ""},
+		{custom, "GET", "", 200, `"v3"`},
+		{custom, "GET", `"v3"`, 304, `"v3"`},
+		{custom, "GET", `"v2"`, 200, `"v3"`},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest(c.method, "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.inm != "" {
+			r.Header.Set("If-None-Match", c.inm)
+		}
+		c.h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if got := rec.Header().Get("ETag"); got != c.wantETag {
+			t.Errorf("case %d: got ETag %q want %q", i, got, c.wantETag)
+		}
+		if c.want == 304 && (rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "") {
+			t.Errorf("case %d: 304 with body %q and Content-Type %q", i, rec.Body, rec.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/ryandotsmith/jh
 
-go 1.19
+go 1.22
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	google.golang.org/protobuf v1.36.6
+)
@@ -0,0 +1,194 @@
+package jh
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// GzipMinSize is the smallest response body, in bytes,
+// that [Gzip] compresses.
+var GzipMinSize = 1024
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// pooledGzip returns its gzip.Writer to
+// gzipWriters when it's closed.
+type pooledGzip struct {
+	*gzip.Writer
+}
+
+func (gz pooledGzip) Close() error {
+	err := gz.Writer.Close()
+	gzipWriters.Put(gz.Writer)
+	return err
+}
+
+// Gzip compresses the responses of h for requests that
+// accept the gzip encoding. Bodies smaller than [GzipMinSize]
+// and responses that already have a Content-Encoding
+// are sent as is. See [Compress] for other encodings.
+//
+//	http.Handle("/add", Gzip(HandlerFunc(add)))
+func Gzip(h http.Handler) http.Handler {
+	return Compress(CompressOptions{})(h)
+}
+
+// compressWriter buffers the start of a body until it knows
+// whether the body is big enough to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	enc         Encoding
+	minSize     int
+	status      int
+	wroteHeader bool
+
+	buf     []byte
+	decided bool
+	cw      io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if !bodyAllowed(code) {
+		w.decided = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.minSize {
+		return len(p), nil
+	}
+	if err := w.decide(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decide writes the header and the buffered body
+// compressing them when compress is set and the
+// response isn't already encoded.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	hdr := w.Header()
+	if compress && hdr.Get("Content-Encoding") == "" {
+		hdr.Set("Content-Encoding", w.enc.Name)
+		hdr.Del("Content-Length")
+		w.cw = w.enc.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if w.cw != nil {
+		_, err := w.cw.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush sends the buffered body, compressed or not, and
+// flushes the wrapped ResponseWriter, so streamed responses
+// like [SSE] reach the client as they're written.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements [http.Hijacker] when the wrapped
+// ResponseWriter supports hijacking. Nothing is written
+// to a hijacked connection.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.decided = true
+		w.buf, w.cw = nil, nil
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets [http.ResponseController] reach w's
+// ResponseWriter, eg to set deadlines.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+		w.cw = nil
+	}
+}
+
+// bodyAllowed reports whether a response
+// with the given status can have a body.
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// decompress replaces r's body with a reader that decompresses
+// it according to r's Content-Encoding. It reports whether
+// the body was replaced.
+func decompress(r *http.Request) (bool, error) {
+	var (
+		rc  io.ReadCloser
+		err error
+	)
+	switch coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); coding {
+	case "", "identity":
+		return false, nil
+	case "gzip", "x-gzip":
+		rc, err = gzip.NewReader(r.Body)
+	case "deflate":
+		rc, err = zlib.NewReader(r.Body)
+	default:
+		return false, Error{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("unsupported Content-Encoding %q", coding),
+		}
+	}
+	if err != nil {
+		return false, decodeError(err)
+	}
+	r.Body = rc
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package jh
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	type blob struct {
+		Data string
+	}
+	h := Gzip(HandlerFunc(func(ctx context.Context, n struct{ N int }) (*blob, error) {
+		return &blob{strings.Repeat("a", n.N)}, nil
+	}))
+
+	cases := []struct {
+		n            int
+		accept       string
+		wantEncoding string
+	}{
+		{2000, "gzip, deflate", "gzip"},
+		{2000, "deflate, gzip;q=0", ""},
+		{2000, "", ""},
+		{10, "gzip", ""},
+	}
+	for _, c := range cases {
+		var (
+			body = strings.NewReader(fmt.Sprintf(`{"N":%d}`, c.n))
+			r    = httptest.NewRequest("POST", "/", body)
+			rec  = httptest.NewRecorder()
+		)
+		r.Header.Set("Accept-Encoding", c.accept)
+		h.ServeHTTP(rec, r)
+
+		if got := rec.Header().Get("Content-Encoding"); got != c.wantEncoding {
+			t.Errorf("%d %q: got %q want %q", c.n, c.accept, got, c.wantEncoding)
+		}
+		if got := rec.Header().Get("Vary"); !strings.Contains(got, "Accept-Encoding") {
+			t.Errorf("%d %q: got Vary %q", c.n, c.accept, got)
+		}
+		var rd io.Reader = rec.Body
+		if c.wantEncoding == "gzip" {
+			gz, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rd = gz
+		}
+		got, _ := io.ReadAll(rd)
+		want := `{"Data":"` + strings.Repeat("a", c.n) + "\"}\n"
+		if string(got) != want {
+			t.Errorf("%d %q: got %d bytes want %d", c.n, c.accept, len(got), len(want))
+		}
+	}
+}
+
+func TestDecompressRequest(t *testing.T) {
+	var gzbody, zbody bytes.Buffer
+	gz := gzip.NewWriter(&gzbody)
+	gz.Write([]byte(`{"x": 1, "y": 2}`))
+	gz.Close()
+	var bomb bytes.Buffer
+	gz = gzip.NewWriter(&bomb)
+	gz.Write([]byte(`{"x": 1,` + strings.Repeat(" ", 4096) + `"y": 2}`))
+	gz.Close()
+	zw := zlib.NewWriter(&zbody)
+	zw.Write([]byte(`{"x": 3, "y": 4}`))
+	zw.Close()
+
+	cases := []struct {
+		encoding   string
+		body       []byte
+		opts       []Option
+		wantStatus int
+		wantBody   string
+	}{
+		{"gzip", gzbody.Bytes(), nil, 200, "{\"x\":1,\"y\":2}\n"},
+		{"deflate", zbody.Bytes(), nil, 200, "{\"x\":3,\"y\":4}\n"},
+		{"gzip", []byte("not gzip"), nil, 400, ""},
+		{"gzip", gzbody.Bytes()[:gzbody.Len()/2], nil, 400, ""},
+		{"gzip", bomb.Bytes(), []Option{MaxBodyBytes(1024)}, 413, ""},
+		{"br", gzbody.Bytes(), nil, 415, ""},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", bytes.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Content-Encoding", c.encoding)
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.encoding, rec.Code, c.wantStatus)
+		}
+		if c.wantBody != "" && rec.Body.String() != c.wantBody {
+			t.Errorf("%s: got = %q; want %q", c.encoding, rec.Body, c.wantBody)
+		}
+	}
+}
+
+func TestGzipFlush(t *testing.T) {
+	h := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 2000))
+		w.(http.Flusher).Flush()
+	}))
+	var (
+		r   = httptest.NewRequest("GET", "/", nil)
+		rec = httptest.NewRecorder()
+	)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rec, r)
+	if !rec.Flushed {
+		t.Error("not flushed")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("got encoding %q want gzip", got)
+	}
+}
+
+func TestGzipHijack(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	h := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			server.Close()
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("hijacked\n")
+		rw.Flush()
+	}))
+	rec := &hijackRecorder{httptest.NewRecorder(), server}
+	go h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	got, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(got) != "hijacked" {
+		t.Errorf("got %q want hijacked", got)
+	}
+}
@@ -14,21 +14,90 @@
 			return &resp{r.X+r.Y}, nil
 		}
 
-		http.Handle("/add", Handler(add), ErrHandler)
+		http.Handle("/add", HandlerFunc(add))
 
 
 	In this example, add is a wrapped function that jh
 	will use to determine how to encode/decode json.
+
+	HandlerFunc is the preferred way to wrap a function since
+	the compiler checks its signature. Handler does the same
+	thing using reflection and is useful when the wrapped
+	function's type isn't known until runtime:
+
+		h, err := Handler(add, ErrHandler)
+
+	Request fields can be bound from outside of the body with
+	struct tags:
+
+		type getUser struct {
+			ID     int    `path:"id"`
+			Fields string `query:"fields"`
+			Tenant string `header:"X-Tenant-ID" jh:"required"`
+			Token  string `cookie:"session"`
+		}
+
+	Values are converted to the field's type. A time.Duration is
+	parsed like "30s" and a time.Time as RFC 3339, eg
+	"2024-01-01T00:00:00Z", unless it's tagged with another
+	layout like `time_format:"2006-01-02"`. The tag applies to
+	times in JSON bodies as well. Fields can be strings, bools,
+	numbers, durations and times, pointers to them and slices of
+	them; Handler returns an error for tagged fields of other
+	types and HandlerFunc panics. Missing values leave the field
+	alone unless it's tagged `jh:"required"`, which makes
+	them a 400. Path values are always required.
+	The body is decoded first and then headers, cookies, query
+	parameters and path parameters are bound, so later sources
+	win when several set the same field.
+
+	Body fields, including those of nested structs, can be
+	tagged `jh:"required"` too. JSON bodies without them, or
+	with them set to null, are a 400 naming the fields.
+
+	Fields tagged `default:"20"` that are still zero once
+	the request is decoded and bound get the tag's value.
+	Handler returns an error for a tag that doesn't parse as
+	its field's type and HandlerFunc panics.
+
+	Fields of embedded structs are bound as if they were the
+	request's own, like encoding/json does for bodies, so common
+	fields can be shared:
+
+		type Page struct {
+			Limit int    `query:"limit" default:"20"`
+			After string `query:"after"`
+		}
+
+		type listUsers struct {
+			Page
+			Tenant string `header:"X-Tenant-ID"`
+		}
+
+	Embedded pointers are allocated when one of their
+	fields is set.
+
+	HTML form posts, with an application/x-www-form-urlencoded
+	or multipart/form-data body, aren't decoded as JSON. Their
+	values are bound to fields tagged `form:"name"` instead and
+	the files of multipart forms to fields tagged `file:"name"`.
 */
 package jh
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type key int
@@ -36,8 +105,48 @@ type key int
 const (
 	reqKey = iota
 	respKey
+	stateKey
+	requestIDKey
+	principalKey
+	paramsKey
+	txKey
 )
 
+// state is the per-request data that wrapped functions
+// can change using the package's helpers.
+type state struct {
+	status int
+
+	// err is the error passed to the error func
+	// or the error writing a response.
+	err error
+
+	// streamed is set once a streaming handler, like
+	// [SSE], has written the response itself.
+	streamed bool
+
+	// rawBody holds the request body read
+	// when the [TeeBody] option is used.
+	rawBody *limitedBuffer
+
+	// operation is the handler's operation ID. See [Operation].
+	operation string
+
+	// location is the Location header set by [Created]
+	// or, when redirect is set, the URL set by [Redirect].
+	location string
+	redirect int
+
+	// warnings are added with [Warn]. mu guards
+	// them since Warn can be called concurrently.
+	mu       sync.Mutex
+	warnings []string
+
+	// rw wraps the ResponseWriter the handler writes to.
+	// See [Written].
+	rw responseWriter
+}
+
 // Can be used inside of a wrapped function.
 // eg parsing url parameters
 func Request(ctx context.Context) *http.Request {
@@ -50,117 +159,1453 @@ func ResponseWriter(ctx context.Context) http.ResponseWriter {
 	return ctx.Value(respKey).(http.ResponseWriter)
 }
 
+// Can be used inside of a wrapped function
+// to set the status code of a successful response.
+// eg http.StatusCreated
+func WithStatus(ctx context.Context, code int) {
+	ctx.Value(stateKey).(*state).status = code
+}
+
+// Written returns the status and the number of body bytes
+// written so far for the request served with ctx, eg for a
+// [LogFunc] to log the size of responses. The status is 200
+// when nothing was written, matching net/http, and 0 outside
+// of handlers returned by [Handler] or [HandlerFunc].
+func Written(ctx context.Context) (status int, n int64) {
+	st, ok := ctx.Value(stateKey).(*state)
+	if !ok {
+		return 0, 0
+	}
+	return st.rw.Status(), st.rw.Size()
+}
+
+// Operation returns the operation ID of the handler serving
+// the request, eg to label logs in a [LogFunc]. It's the
+// [OperationID] option's value or, for routes of a [Mux], one
+// derived from the route's pattern. It's empty elsewhere.
+func Operation(ctx context.Context) string {
+	st, ok := ctx.Value(stateKey).(*state)
+	if !ok {
+		return ""
+	}
+	return st.operation
+}
+
+// Created makes a successful response a 201 Created with a
+// Location header pointing to the new resource, eg in a wrapped
+// function handling POST /widgets:
+//
+//	jh.Created(ctx, "/widgets/"+id)
+//	return &w, nil
+//
+// The response is encoded as usual. The header isn't
+// set when the wrapped function returns an error.
+func Created(ctx context.Context, location string) {
+	st := ctx.Value(stateKey).(*state)
+	st.status = http.StatusCreated
+	st.location = location
+}
+
+// Redirect makes a successful response a redirect to url with
+// code, eg http.StatusFound or http.StatusTemporaryRedirect for
+// temporary redirects and http.StatusMovedPermanently or
+// http.StatusPermanentRedirect for permanent ones:
+//
+//	jh.Redirect(ctx, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusFound)
+//	return nil, nil
+//
+// The response is written by [http.Redirect] in place of the
+// wrapped function's. Like [Created], it's ignored when the
+// wrapped function returns an error.
+func Redirect(ctx context.Context, url string, code int) {
+	st := ctx.Value(stateKey).(*state)
+	st.redirect = code
+	st.location = url
+}
+
+// RawBody returns the request body read by the handler when the
+// [TeeBody] option is used, eg for audit logging. It's nil
+// otherwise. Besides the wrapped function, the error func, the
+// [BeforeWrite] hook and the [LogFunc] can use it.
+// Compressed bodies are returned decompressed.
+func RawBody(ctx context.Context) []byte {
+	st, ok := ctx.Value(stateKey).(*state)
+	if !ok || st.rawBody == nil {
+		return nil
+	}
+	return st.rawBody.Bytes()
+}
+
+// Response types can implement StatusCoder to set the
+// status code of a successful response. A status set
+// using [WithStatus] takes precedence.
+type StatusCoder interface {
+	StatusCode() int
+}
+
 type handler struct {
-	f  reflect.Value
 	ef func(context.Context, http.ResponseWriter, error)
+
+	// reqType and respType are the wrapped function's
+	// request and response types. They are nil when the
+	// function doesn't take a request or return a response.
+	reqType  reflect.Type
+	respType reflect.Type
+
+	maxBodyBytes int64
+	maxMemory    int64
+	json         jsonCodec
+	jsonEnc      Encoder
+	jsonDec      Decoder
+
+	// codecs are added with the [Codec] option and
+	// take precedence over registered codecs.
+	codecs map[string]codec
+
+	onPanic       func(ctx context.Context, v any, stack []byte) error
+	recoverPanics bool
+	timeout       time.Duration
+
+	// timeoutHeader names the header that can shorten timeout.
+	// Invalid values are a 400 when strictTimeoutHeader is set.
+	timeoutHeader       string
+	strictTimeoutHeader bool
+
+	// requireContentType rejects request bodies whose
+	// Content-Type doesn't have a registered codec.
+	requireContentType bool
+
+	// bufferResponse encodes responses into a
+	// buffer before writing any of them.
+	bufferResponse bool
+
+	// maxBufferSize is the capacity up to which buffers holding
+	// request bodies are reused. Zero disables reuse.
+	maxBufferSize int
+
+	// requireContentLength rejects POST, PUT and PATCH
+	// requests without a Content-Length.
+	requireContentLength bool
+
+	// operationID names the handler's operation. See [OperationID].
+	operationID string
+
+	// exampleReq and exampleResp are set by the [Example] option.
+	exampleReq, exampleResp any
+
+	// envelope wraps responses before they're encoded.
+	envelope func(ctx context.Context, resp any) any
+
+	// etags is set by the [ETags] option.
+	etags bool
+
+	// contentType replaces the Content-Type of JSON responses.
+	contentType string
+
+	beforeWrite func(context.Context, http.ResponseWriter, any) error
+
+	teeBody bool
+
+	// validationStatus replaces the 400 Code of errors
+	// for input that fails validation, when set.
+	validationStatus int
+
+	// schema validates JSON request bodies before they're
+	// decoded. schemaErr is the error parsing it.
+	schema    *jsonSchema
+	schemaErr error
+
+	// allowEmptyBody decodes empty request bodies as the zero value.
+	allowEmptyBody bool
+
+	// setDefaults is set when the request type has default tags.
+	// defaultsErr is the error parsing them.
+	setDefaults bool
+	defaultsErr error
+
+	// bindErr is the error of a field tagged for binding
+	// that has a type the binders can't set.
+	bindErr error
+
+	// checkRequired is set when the request type has body
+	// fields tagged `jh:"required"`. See [checkRequired].
+	checkRequired bool
+
+	// formatTimes is set when the request type has body fields
+	// with a time_format tag. See [formatTimes].
+	formatTimes bool
+
+	// bindHeader, bindCookie, bindQuery and bindPath are set
+	// when the request type has header, cookie, query or path tags.
+	bindHeader bool
+	bindCookie bool
+	bindQuery  bool
+	bindPath   bool
+
+	// newReq returns a pointer to a new request value.
+	// It is nil when the wrapped function doesn't take a request.
+	newReq func() any
+
+	// reqs holds request values for reuse once resetReq
+	// has zeroed them. Pooling is off when resetReq is nil.
+	reqs     sync.Pool
+	resetReq func(req any)
+
+	// validate checks the value returned by newReq.
+	// It is nil when the request type isn't a [Validator].
+	validate func(req any) error
+
+	// bind calls the Bind method of the value returned by newReq.
+	// It is nil when the request type isn't a [Binder].
+	bind func(req any, r *http.Request) error
+
+	// paramsOnly is set for [HandlerWithParams] handlers, whose
+	// request values are bound from everything but the body.
+	paramsOnly bool
+
+	// noContent is set when the wrapped function only returns an error.
+	noContent bool
+
+	// call invokes the wrapped function.
+	// req is the value returned by newReq.
+	// Nil pointer responses are returned as nil.
+	call func(ctx context.Context, req any) (any, error)
 }
 
 type Error struct {
 	Code    int    `json:"-"`
 	Message string `json:"message"`
+
+	// Kind is a machine-readable code for the error, eg
+	// "user_not_found", that clients can switch on. Unlike Code,
+	// the HTTP status, it's sent in the body and omitted when empty.
+	Kind string `json:"kind,omitempty"`
+
+	// Details holds extra information about the error, eg a
+	// message for each invalid field of a request. It's
+	// omitted from the response body when empty.
+	Details map[string]any `json:"details,omitempty"`
 }
 
 func (e Error) Error() string {
 	return fmt.Sprintf("jh: %s", e.Message)
 }
 
-func ErrHandler(ctx context.Context, w http.ResponseWriter, err error) {
-	var jhe Error
+// Errorf returns an [Error] with code and
+// a message formatted like [fmt.Sprintf].
+func Errorf(code int, format string, args ...any) Error {
+	return Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest returns an [Error] with a 400 Code.
+func BadRequest(msg string) Error {
+	return Error{Code: http.StatusBadRequest, Message: msg}
+}
+
+// NotFound returns an [Error] with a 404 Code.
+func NotFound(msg string) Error {
+	return Error{Code: http.StatusNotFound, Message: msg}
+}
+
+// Conflict returns an [Error] with a 409 Code.
+func Conflict(msg string) Error {
+	return Error{Code: http.StatusConflict, Message: msg}
+}
+
+// StatusError wraps an error with the status to respond with,
+// eg to pass on the status of a failed call to an upstream service:
+//
+//	if res.StatusCode >= 500 {
+//		return nil, jh.StatusError{Code: http.StatusBadGateway, Err: err}
+//	}
+//
+// Unlike an [Error], whose message is sent to clients, Err is only
+// for logging. [ErrHandler] sends Code with the message of an Error
+// that Err is, or wraps, or else with Code's status text, eg
+// "Bad Gateway". [errors.As] reaches both StatusError and Err.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("jh: %d: %v", e.Code, e.Err)
+}
+
+func (e StatusError) Unwrap() error {
+	return e.Err
+}
+
+// clientError returns the [Error] sent to clients for err when err
+// is, or wraps, an Error or a [StatusError]. A StatusError takes
+// precedence so the status it wraps an error with is kept.
+func clientError(err error) (Error, bool) {
+	var (
+		se  StatusError
+		jhe Error
+	)
+	if errors.As(err, &se) {
+		if !errors.As(se.Err, &jhe) {
+			jhe = Error{Message: http.StatusText(se.Code)}
+		}
+		jhe.Code = se.Code
+		return jhe, true
+	}
 	if errors.As(err, &jhe) {
-		w.WriteHeader(jhe.Code)
+		return jhe, true
+	}
+	return Error{}, false
+}
+
+// PanicError is passed to the error func when a wrapped
+// function panics. Stack is the panicking goroutine's stack.
+// It isn't part of the message so [ErrHandler] doesn't
+// send it to clients but logging can use it.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("jh: panic: %v", e.Value)
+}
+
+// Unwrap returns Value when it's an error.
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Request types can implement Validator to check their
+// values after decoding and before the wrapped function is called.
+// Errors are passed to the error func. A returned [Error] keeps
+// its Code, all other errors become a 400.
+type Validator interface {
+	Validate() error
+}
+
+// Request types can implement Binder to populate themselves from
+// the request in ways struct tags can't, eg combining a body field
+// with a header. Bind is called once the body is decoded and the
+// tagged fields are bound, before defaults are set and the
+// request is validated. Errors are passed to the error func.
+// A returned [Error] keeps its Code, all other errors become a 400.
+// Bind shouldn't read the body, which has been consumed.
+type Binder interface {
+	Bind(r *http.Request) error
+}
+
+var registeredErrors = struct {
+	sync.RWMutex
+	s []registeredError
+}{}
+
+type registeredError struct {
+	target error
+	code   int
+}
+
+// RegisterError makes [ErrHandler] respond with code to errors
+// that match target using [errors.Is]. The error's message is
+// sent as an [Error]'s would be. Targets are checked in the
+// order they were registered and registering a target
+// again replaces its code.
+//
+//	jh.RegisterError(sql.ErrNoRows, http.StatusNotFound)
+func RegisterError(target error, code int) {
+	registeredErrors.Lock()
+	defer registeredErrors.Unlock()
+	for i, re := range registeredErrors.s {
+		if re.target == target {
+			registeredErrors.s[i].code = code
+			return
+		}
+	}
+	registeredErrors.s = append(registeredErrors.s, registeredError{target, code})
+}
+
+// registeredCode returns the code registered
+// for err with [RegisterError].
+func registeredCode(err error) (int, bool) {
+	registeredErrors.RLock()
+	defer registeredErrors.RUnlock()
+	for _, re := range registeredErrors.s {
+		if errors.Is(err, re.target) {
+			return re.code, true
+		}
+	}
+	return 0, false
+}
+
+// responseError returns the [Error] sent to clients for err: the
+// Error that err is or wraps, one with the code registered with
+// [RegisterError] or else one with [DefaultErrorStatus] and
+// [DefaultErrorMessage], the only case that isn't known.
+// Every error message clients get comes from it.
+func responseError(err error) (jhe Error, known bool) {
+	if jhe, ok := clientError(err); ok {
+		return jhe, true
+	}
+	if code, ok := registeredCode(err); ok {
+		return Error{Code: code, Message: err.Error()}, true
+	}
+	jhe = Error{Code: DefaultErrorStatus, Message: DefaultErrorMessage}
+	if jhe.Message == "" {
+		jhe.Message = err.Error()
+	}
+	return jhe, false
+}
+
+// ErrorOf returns the [Error] that [ErrHandler] sends for err,
+// for code that reports errors to clients in other ways, eg over
+// a WebSocket. Like ErrHandler it uses [DefaultErrorMessage] for
+// errors that aren't an Error and have no code registered with
+// [RegisterError], so their text doesn't reach clients.
+func ErrorOf(err error) Error {
+	jhe, _ := responseError(err)
+	return jhe
+}
+
+// DefaultErrorStatus is the status [ErrHandler] writes, and [Batch]
+// reports, for errors that aren't an [Error] and have no code
+// registered with [RegisterError].
+var DefaultErrorStatus = http.StatusInternalServerError
+
+// DefaultErrorMessage replaces the message [ErrHandler], [Batch],
+// stream errors and [Health] checks send for errors that aren't an [Error] and have no code registered
+// with [RegisterError], eg "internal error", so their text doesn't
+// reach clients. The error is still passed to the [LogFunc].
+// Empty means the error's message is sent, which is
+// handy during development.
+var DefaultErrorMessage string
+
+// ErrorBody, when set, returns the value [ErrHandler] encodes as the
+// body of every error response, so all errors share one format:
+//
+//	jh.ErrorBody = func(e jh.Error, err error) any {
+//		return map[string]any{"error": map[string]any{
+//			"message": e.Message,
+//			"status":  e.Code,
+//		}}
+//	}
+//
+// e is what ErrHandler would send otherwise: the [Error] that err
+// is or wraps, or an Error with the code registered with
+// [RegisterError] or with [DefaultErrorStatus] and
+// [DefaultErrorMessage]. err is the error passed to ErrHandler.
+// By default an Error is sent as {"message": "..."} and other
+// errors as {"error": "..."}.
+var ErrorBody func(e Error, err error) any
+
+func ErrHandler(ctx context.Context, w http.ResponseWriter, err error) {
+	jhe, known := responseError(err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(jhe.Code)
+	switch {
+	case ErrorBody != nil:
+		json.NewEncoder(w).Encode(ErrorBody(jhe, err))
+	case known:
 		json.NewEncoder(w).Encode(jhe)
-		return
+	default:
+		json.NewEncoder(w).Encode(&struct {
+			Messages string `json:"error"`
+		}{jhe.Message})
+	}
+}
+
+// decodeError converts an error from decoding
+// a request body into an [Error]. Common JSON errors get
+// messages that name the offending field and expected type
+// instead of encoding/json's.
+func decodeError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return DecodeError{
+			Err: Error{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("request body exceeds %d bytes", mbe.Limit),
+			},
+			Cause: err,
+		}
+	}
+	var (
+		te  *json.UnmarshalTypeError
+		se  *json.SyntaxError
+		msg string
+	)
+	switch {
+	case errors.As(err, &te):
+		msg = typeErrorMessage(te)
+	case errors.As(err, &se):
+		msg = fmt.Sprintf("malformed JSON at byte %d", se.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg = "malformed JSON: body ends unexpectedly"
+	case errors.Is(err, io.EOF):
+		msg = "request body is empty"
+	default:
+		msg = err.Error()
+	}
+	return DecodeError{
+		Err:   Error{Code: http.StatusBadRequest, Message: msg},
+		Cause: err,
+	}
+}
+
+// isSemantic reports whether the decoding error err is
+// about well-formed input that doesn't fit the request type.
+func isSemantic(err error) bool {
+	var te *json.UnmarshalTypeError
+	return errors.As(err, &te) || strings.HasPrefix(err.Error(), "json: unknown field ")
+}
+
+// invalid gives err, a 400 [Error] or [DecodeError] for input that
+// fails validation, the [ValidationStatus] code instead.
+func (h *handler) invalid(err error) error {
+	if h.validationStatus == 0 {
+		return err
+	}
+	switch e := err.(type) {
+	case Error:
+		if e.Code == http.StatusBadRequest {
+			e.Code = h.validationStatus
+		}
+		return e
+	case DecodeError:
+		if e.Err.Code == http.StatusBadRequest {
+			e.Err.Code = h.validationStatus
+		}
+		return e
+	}
+	return err
+}
+
+// A DecodeError is passed to the error func when the request
+// can't be decoded, bound or validated, so error funcs can tell
+// problems with the client's input apart from errors returned by
+// the wrapped function. It unwraps to its [Error], which
+// [ErrHandler] writes, and to the error that caused it, if any:
+//
+//	var de jh.DecodeError
+//	if errors.As(err, &de) {
+//		log.Printf("bad request: %v", de.Err.Message)
+//	}
+type DecodeError struct {
+	Err   Error
+	Cause error // eg a *json.SyntaxError, or nil
+}
+
+func (e DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e DecodeError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
 	}
+	return []error{e.Err, e.Cause}
+}
 
-	w.WriteHeader(http.StatusInternalServerError)
-	json.NewEncoder(w).Encode(&struct {
-		Messages string `json:"error"`
-	}{err.Error()})
+// asDecodeError wraps the [Error] that err is, or wraps,
+// in a DecodeError. Other errors are returned as is.
+func asDecodeError(err error) error {
+	var (
+		de  DecodeError
+		jhe Error
+	)
+	if errors.As(err, &de) || !errors.As(err, &jhe) {
+		return err
+	}
+	de.Err = jhe
+	if _, ok := err.(Error); !ok {
+		de.Cause = err
+	}
+	return de
 }
 
 var (
 	ErrTooFewArgs  = errors.New("jh: handler: too few args. expected wrappedFunc with at least 1 arg")
 	ErrTooManyArgs = errors.New("jh: handler: too many args. expected wrappedFunc with no more than 2 args")
 	ErrMissingCtx  = errors.New("jh: handler: 1st arg must be context.Context")
-	ErrNumRet      = errors.New("jh: handler: expected wrappedFunc to have 2 return values")
-	ErrMissingErr  = errors.New("jh: handler: wrappedFunc's 2nd return value must be an error")
+	ErrNumRet      = errors.New("jh: handler: expected wrappedFunc to have 1 or 2 return values")
+	ErrMissingErr  = errors.New("jh: handler: wrappedFunc's last return value must be an error")
+	ErrRespType    = errors.New("jh: handler: wrappedFunc's response can't be a chan, func or complex number")
 )
 
+// A SignatureError is returned by [Handler] when wrappedFunc
+// has the wrong signature. It wraps one of ErrTooFewArgs,
+// ErrTooManyArgs, ErrMissingCtx, ErrNumRet, ErrMissingErr
+// or ErrRespType, so they can be checked with [errors.Is].
+type SignatureError struct {
+	Func    string // wrappedFunc's type, eg "func(int) error"
+	Problem string // eg "arg 1 is int, want context.Context"
+	Err     error
+}
+
+func signatureError(t reflect.Type, err error, format string, args ...any) *SignatureError {
+	return &SignatureError{
+		Func:    t.String(),
+		Problem: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+func (e *SignatureError) Error() string {
+	return "jh: handler: " + e.Func + ": " + e.Problem
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
 // Reflection is used on wrappedFunc to determine the req/resp
 // types for later json encoding/decoding.
 // An error is returned when wrappedFunc doesn't conform to one of the
 // following forms:
-//		func(context.Context, struct{}) (*struct{}, error)
-//		func(context.Context) (*struct{}, error)
+//
+//	func(context.Context, struct{}) (*struct{}, error)
+//	func(context.Context) (*struct{}, error)
+//	func(context.Context, struct{}) error
+//	func(context.Context) error
+//
+// The first arg can also be a type embedding context.Context,
+// eg a struct{ context.Context } or a pointer to one, which
+// is filled in with the request's context. Interfaces extending
+// context.Context are passed the request's context as is; the
+// error func gets an error when it doesn't implement them.
+//
+// The response doesn't have to be a struct pointer. Slices,
+// maps, structs and basic types like string or int are encoded
+// as the top level value, eg a []int is sent as [1,2,3] and
+// a nil slice as null. Chans, funcs and complex numbers can't
+// be encoded and return [ErrRespType], except for funcs
+// like [http.HandlerFunc] that are an [http.Handler].
+//
+// A response that is an [http.Handler], eg a file server or a
+// reverse proxy, serves the request instead of being encoded.
+// This lets a wrappedFunc returning an http.Handler
+// delegate to another handler when it needs to.
+//
+// A response that is an [io.Reader], eg a file download, or an
+// [io.WriterTo] is streamed to the body as is. When it's also an
+// [io.Closer] it's closed once the request is served, even when
+// writing it fails.
+//
+// Errors about wrappedFunc's signature are a [*SignatureError].
+//
+// Successful calls of wrappedFuncs that only return an
+// error get a 204 No Content response, as do calls that
+// return a nil pointer or nil interface response.
 //
 // errFunc is called when a wrappedFunc returns an error or
 // when json encoding/decdoing encounters an error.
+// A nil errFunc means [ErrHandler].
+//
+// opts are applied in order.
 func Handler(
 	wrappedFunc any,
 	errFunc func(context.Context, http.ResponseWriter, error),
+	opts ...Option,
 ) (http.Handler, error) {
-	var f = reflect.ValueOf(wrappedFunc)
+	h, err := reflectHandler(wrappedFunc, errFunc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkOptions(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
 
-	if f.Type().NumIn() > 2 {
-		return nil, ErrTooManyArgs
+// reflectHandler is [Handler] without the checks of its options.
+func reflectHandler(
+	wrappedFunc any,
+	errFunc func(context.Context, http.ResponseWriter, error),
+	opts []Option,
+) (*handler, error) {
+	var (
+		f  = reflect.ValueOf(wrappedFunc)
+		ft = f.Type()
+	)
+	if ft.NumIn() > 2 {
+		return nil, signatureError(ft, ErrTooManyArgs, "has %d args, want 1 or 2", ft.NumIn())
+	}
+	if ft.NumIn() < 1 {
+		return nil, signatureError(ft, ErrTooFewArgs, "has no args, want 1 or 2")
 	}
-	if f.Type().NumIn() < 1 {
-		return nil, ErrTooFewArgs
+	numOut := ft.NumOut()
+	if numOut < 1 || numOut > 2 {
+		return nil, signatureError(ft, ErrNumRet, "has %d return values, want 1 or 2", numOut)
 	}
-	if f.Type().NumOut() != 2 {
-		return nil, ErrNumRet
+	if !ft.In(0).Implements(contextType) {
+		return nil, signatureError(ft, ErrMissingCtx, "arg 1 is %s, want context.Context", ft.In(0))
 	}
-	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
-	if !f.Type().In(0).Implements(contextType) {
-		return nil, ErrMissingCtx
+	toCtx, ok := ctxArg(ft.In(0))
+	if !ok {
+		return nil, signatureError(ft, ErrMissingCtx, "arg 1 is %s, which doesn't embed context.Context", ft.In(0))
 	}
 	errorType := reflect.TypeOf((*error)(nil)).Elem()
-	if !f.Type().Out(1).Implements(errorType) {
-		return nil, ErrMissingErr
+	if !ft.Out(numOut - 1).Implements(errorType) {
+		return nil, signatureError(ft, ErrMissingErr, "return value %d is %s, want error", numOut, ft.Out(numOut-1))
+	}
+
+	h := newHandler(errFunc, opts)
+	h.noContent = numOut == 1
+	if numOut == 2 {
+		h.setRespType(ft.Out(0))
+		switch h.respType.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			if !h.respType.Implements(handlerType) {
+				return nil, signatureError(ft, ErrRespType, "return value 1 is %s, which can't be encoded", h.respType)
+			}
+		}
+	}
+	if ft.NumIn() == 2 {
+		h.setRequest(ft.In(1))
+	}
+	numIn := ft.NumIn()
+	h.call = func(ctx context.Context, req any) (any, error) {
+		var (
+			args [2]reflect.Value
+			err  error
+		)
+		args[0], err = toCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if numIn == 2 {
+			args[1] = reflect.ValueOf(req).Elem()
+		}
+		ret := f.Call(args[:numIn])
+		err, _ = ret[len(ret)-1].Interface().(error)
+		if len(ret) == 1 || isNil(ret[0]) {
+			return nil, err
+		}
+		return ret[0].Interface(), err
 	}
+	return h, nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	handlerType = reflect.TypeOf((*http.Handler)(nil)).Elem()
+)
 
-	return &handler{
-		f:  f,
-		ef: errFunc,
-	}, nil
+// ctxArg returns a function converting a request's context into
+// a wrappedFunc's first arg of type t. Contexts that already are
+// a t are passed unchanged. Otherwise t must be a struct, or
+// a pointer to one, embedding context.Context, which is set
+// to the request's context.
+func ctxArg(t reflect.Type) (func(context.Context) (reflect.Value, error), bool) {
+	if t == contextType {
+		return func(ctx context.Context) (reflect.Value, error) {
+			return reflect.ValueOf(ctx), nil
+		}, true
+	}
+	st := t
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+	embedded := -1
+	if st.Kind() == reflect.Struct {
+		for i := 0; i < st.NumField(); i++ {
+			if sf := st.Field(i); sf.Anonymous && sf.Type == contextType {
+				embedded = i
+				break
+			}
+		}
+	}
+	if embedded < 0 && t.Kind() != reflect.Interface {
+		return nil, false
+	}
+	return func(ctx context.Context) (reflect.Value, error) {
+		if reflect.TypeOf(ctx).AssignableTo(t) {
+			return reflect.ValueOf(ctx), nil
+		}
+		if embedded < 0 {
+			return reflect.Value{}, fmt.Errorf("jh: handler: context %T isn't a %s", ctx, t)
+		}
+		v := reflect.New(st)
+		v.Elem().Field(embedded).Set(reflect.ValueOf(&ctx).Elem())
+		if t.Kind() == reflect.Pointer {
+			return v, nil
+		}
+		return v.Elem(), nil
+	}, true
+}
+
+// HandlerFunc is like [Handler] but uses generics instead
+// of reflection. The compiler verifies wrappedFunc's signature
+// and it's called, and its response checked for nil, without
+// reflection.
+// It panics when an [Example] doesn't match the wrapped
+// function's types, a [WithSchema] schema is invalid,
+// a default tag doesn't parse or a field tagged for
+// binding has a type that can't be bound.
+//
+// Errors are handled by [ErrHandler] unless
+// the [ErrFunc] option is used.
+func HandlerFunc[Req, Resp any](
+	wrappedFunc func(context.Context, Req) (*Resp, error),
+	opts ...Option,
+) http.Handler {
+	h := newHandler(ErrHandler, opts)
+	h.setReqType(reflect.TypeOf((*Req)(nil)).Elem())
+	h.setRespType(reflect.TypeOf((*Resp)(nil)))
+	h.newReq = func() any {
+		return new(Req)
+	}
+	h.resetReq = func(req any) {
+		*req.(*Req) = *new(Req)
+	}
+	h.call = func(ctx context.Context, req any) (any, error) {
+		resp, err := wrappedFunc(ctx, *req.(*Req))
+		if resp == nil {
+			return nil, err
+		}
+		return resp, err
+	}
+	if _, ok := any(new(Req)).(Validator); ok {
+		h.validate = func(req any) error {
+			return req.(Validator).Validate()
+		}
+	} else if _, ok := any(*new(Req)).(Validator); ok {
+		// Req is a pointer type
+		h.validate = func(req any) error {
+			return any(*req.(*Req)).(Validator).Validate()
+		}
+	}
+	if _, ok := any(new(Req)).(Binder); ok {
+		h.bind = func(req any, r *http.Request) error {
+			return req.(Binder).Bind(r)
+		}
+	} else if _, ok := any(*new(Req)).(Binder); ok {
+		// Req is a pointer type
+		h.bind = func(req any, r *http.Request) error {
+			return any(*req.(*Req)).(Binder).Bind(r)
+		}
+	}
+	if err := h.checkOptions(); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func newHandler(
+	errFunc func(context.Context, http.ResponseWriter, error),
+	opts []Option,
+) *handler {
+	h := &handler{
+		ef:            errFunc,
+		maxBodyBytes:  DefaultMaxBodyBytes,
+		maxMemory:     32 << 20,
+		recoverPanics: DefaultRecoverPanics,
+		json: jsonCodec{
+			disallowUnknownFields: DefaultDisallowUnknownFields,
+		},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.ef == nil {
+		h.ef = ErrHandler
+	}
+	// box h.json once instead of on every request
+	h.jsonEnc, h.jsonDec = h.json, h.json
+	return h
+}
+
+// checkOptions returns an error when options, or the
+// request type's tags, are invalid for the wrapped function.
+// [Handler] returns it and [HandlerFunc] panics with it.
+func (h *handler) checkOptions() error {
+	if h.schemaErr != nil {
+		return h.schemaErr
+	}
+	if h.defaultsErr != nil {
+		return h.defaultsErr
+	}
+	if h.bindErr != nil {
+		return h.bindErr
+	}
+	return h.checkExample()
+}
+
+// checkExample reports whether the [Example]
+// payloads match the wrapped function's types.
+func (h *handler) checkExample() error {
+	check := func(kind string, v any, want reflect.Type) error {
+		if v == nil {
+			return nil
+		}
+		t := reflect.TypeOf(v)
+		switch {
+		case want == nil:
+			return fmt.Errorf("jh: example %s for a wrappedFunc without one", kind)
+		case t.AssignableTo(want):
+			return nil
+		case want.Kind() == reflect.Pointer && t.AssignableTo(want.Elem()):
+			return nil
+		}
+		return fmt.Errorf("jh: example %s has type %s, want %s", kind, t, want)
+	}
+	if err := check("request", h.exampleReq, h.reqType); err != nil {
+		return err
+	}
+	return check("response", h.exampleResp, h.respType)
+}
+
+// setRequest makes h decode requests into
+// values of type t using reflection.
+func (h *handler) setRequest(t reflect.Type) {
+	h.setReqType(t)
+	h.newReq = func() any {
+		return reflect.New(t).Interface()
+	}
+	h.resetReq = func(req any) {
+		reflect.ValueOf(req).Elem().SetZero()
+	}
+	validatorType := reflect.TypeOf((*Validator)(nil)).Elem()
+	switch {
+	case reflect.PointerTo(t).Implements(validatorType):
+		h.validate = func(req any) error {
+			return req.(Validator).Validate()
+		}
+	case t.Implements(validatorType):
+		h.validate = func(req any) error {
+			return reflect.ValueOf(req).Elem().Interface().(Validator).Validate()
+		}
+	}
+	binderType := reflect.TypeOf((*Binder)(nil)).Elem()
+	switch {
+	case reflect.PointerTo(t).Implements(binderType):
+		h.bind = func(req any, r *http.Request) error {
+			return req.(Binder).Bind(r)
+		}
+	case t.Implements(binderType):
+		h.bind = func(req any, r *http.Request) error {
+			return reflect.ValueOf(req).Elem().Interface().(Binder).Bind(r)
+		}
+	}
+}
+
+// setReqType records what h needs to know about
+// the request type before serving requests.
+func (h *handler) setReqType(t reflect.Type) {
+	h.reqType = t
+	h.bindHeader = hasTag(t, "header")
+	h.setDefaults = hasTag(t, "default")
+	if h.setDefaults {
+		h.defaultsErr = setDefaults(reflect.New(t))
+	}
+	h.checkRequired = hasRequired(t)
+	h.formatTimes = hasTimeFormat(t)
+	h.bindCookie = hasTag(t, "cookie")
+	h.bindQuery = hasTag(t, "query")
+	h.bindPath = hasTag(t, "path")
+	h.bindErr = checkBindable(t)
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	var (
+		st  = &state{operation: h.operationID}
+		ctx = r.Context()
+	)
+	st.rw.ResponseWriter = w
+	w = &st.rw
+	if log := logger.Load(); log != nil {
+		start := time.Now()
+		defer func() {
+			(*log)(ctx, r, st.rw.Status(), time.Since(start), st.err)
+		}()
+	}
+
 	ctx = context.WithValue(ctx, reqKey, r)
 	ctx = context.WithValue(ctx, respKey, w)
+	ctx = context.WithValue(ctx, stateKey, st)
+	setTxState(ctx, st, h.ef)
+	timeout, err := h.requestTimeout(r)
+	if err != nil {
+		st.err = err
+		h.ef(ctx, w, err)
+		return
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := h.run(ctx, w, r)
+	var header http.Header
+	if err == nil {
+		resp, header = unwrapResponse(st, resp)
+	}
+	if c, ok := streamCloser(resp); ok && err == nil {
+		defer c.Close()
+	}
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		err = Error{Code: http.StatusGatewayTimeout, Message: "request timed out"}
+	}
+	if err == nil && h.beforeWrite != nil {
+		err = h.beforeWrite(ctx, w, resp)
+	}
+	if err != nil {
+		st.err = err
+		h.ef(ctx, w, err)
+		return
+	}
+
+	for k, vs := range header {
+		w.Header().Del(k)
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	st.writeWarnings(w)
+	if st.redirect != 0 {
+		http.Redirect(w, r, st.location, st.redirect)
+		return
+	}
+	if st.location != "" {
+		w.Header().Set("Location", st.location)
+	}
+	h.respond(ctx, w, r, st, resp)
+}
+
+// respond writes a successful response.
+//
+// An http.Handler resp serves the request itself
+// and a [Marshaler] encodes itself.
+// An io.WriterTo resp writes itself to the body and an io.Reader
+// resp is copied to it as is. Their Content-Type defaults to
+// application/octet-stream and can be set by the wrapped function
+// using [ResponseWriter] or by the [BeforeWrite] hook. An error
+// while writing them is reported in the [StreamErrorTrailer].
+// Those that are an [io.Closer], like an *os.File, are closed
+// by ServeHTTP once, whether or not they're written.
+// Other values are encoded using the codec that
+// the request's Accept header prefers. Encoding errors
+// go to the error func unless part of the body
+// has been written already.
+func (h *handler) respond(ctx context.Context, w http.ResponseWriter, r *http.Request, st *state, resp any) {
+	if st.streamed {
+		return
+	}
+	if h.noContent || resp == nil {
+		status := http.StatusNoContent
+		if st.status != 0 {
+			status = st.status
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	status := http.StatusOK
+	if st.status != 0 {
+		status = st.status
+	} else if sc, ok := resp.(StatusCoder); ok {
+		status = sc.StatusCode()
+	}
 
-	var ret []reflect.Value
-	switch h.f.Type().NumIn() {
-	case 1:
-		ret = h.f.Call([]reflect.Value{reflect.ValueOf(ctx)})
-	case 2:
-		var i = reflect.New(h.f.Type().In(1))
-		err := json.NewDecoder(r.Body).Decode(i.Interface())
+	if err := r.Context().Err(); err != nil {
+		// the client went away, there's no one to respond to
+		st.err = err
+		return
+	}
+
+	if hd, ok := resp.(http.Handler); ok {
+		hd.ServeHTTP(w, r)
+		return
+	}
+	if m, ok := resp.(Marshaler); ok {
+		h.respondMarshaler(ctx, w, r, st, status, m)
+		return
+	}
+
+	wt, isWriterTo := resp.(io.WriterTo)
+	rd, isReader := resp.(io.Reader)
+	if isWriterTo || isReader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.WriteHeader(status)
+		var err error
+		if isWriterTo {
+			_, err = wt.WriteTo(w)
+		} else {
+			_, err = io.Copy(w, rd)
+		}
 		if err != nil {
-			h.ef(ctx, w, Error{Code: http.StatusBadRequest, Message: err.Error()})
-			return
+			st.err = fmt.Errorf("jh: writing response: %w", err)
+			if r.Context().Err() == nil {
+				setStreamError(w, err)
+			}
 		}
-		ret = h.f.Call([]reflect.Value{
-			reflect.ValueOf(ctx),
-			i.Elem(),
-		})
+		return
 	}
 
-	// should never happen since
-	// since we check the length of f's output list in [Handler]
-	if len(ret) != 2 {
-		h.ef(ctx, w, errors.New("handler needs 2 return values"))
+	body := resp
+	if h.envelope != nil {
+		body = h.envelope(ctx, resp)
+	}
+	c := h.responseCodec(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", c.contentType)
+	w.Header().Add("Vary", "Accept")
+	if h.etags && status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		h.respondETag(ctx, w, r, st, c, resp, body)
+		return
+	}
+	if h.bufferResponse {
+		var buf bytes.Buffer
+		if err := c.enc.Encode(&buf, body); err != nil {
+			h.encodeError(ctx, w, st, err)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(status)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			st.err = fmt.Errorf("jh: writing response: %w", err)
+		}
 		return
 	}
+	lw := &lazyWriter{w: w, status: status}
+	if err := c.enc.Encode(lw, body); err != nil {
+		if !lw.wrote {
+			h.encodeError(ctx, w, st, err)
+			return
+		}
+		st.err = fmt.Errorf("jh: writing response: %w", err)
+		return
+	}
+	if !lw.wrote {
+		w.WriteHeader(status)
+	}
+}
 
-	err, _ := ret[1].Interface().(error)
-	if err != nil {
-		h.ef(ctx, w, err)
+// encodeError passes an error encoding a response
+// that hasn't been written to the error func, after
+// dropping the Content-Type set for the response.
+func (h *handler) encodeError(ctx context.Context, w http.ResponseWriter, st *state, err error) {
+	err = fmt.Errorf("jh: encoding response: %w", err)
+	st.err = err
+	w.Header().Del("Content-Type")
+	h.ef(ctx, w, err)
+}
+
+// lazyWriter writes the status to w before the first
+// byte of the body, so encoders failing before writing
+// anything leave w untouched.
+type lazyWriter struct {
+	w      http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (lw *lazyWriter) Write(p []byte) (int, error) {
+	if !lw.wrote {
+		lw.wrote = true
+		lw.w.WriteHeader(lw.status)
+	}
+	return lw.w.Write(p)
+}
+
+// streamCloser returns resp when it's a streamed response,
+// an [io.Reader] or an [io.WriterTo], that must be closed.
+func streamCloser(resp any) (io.Closer, bool) {
+	switch resp.(type) {
+	case io.Reader, io.WriterTo:
+		c, ok := resp.(io.Closer)
+		return c, ok
+	}
+	return nil, false
+}
+
+// isNil reports whether v is a nil pointer, func or
+// interface, or an interface holding a nil pointer or func.
+func isNil(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Func, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// codec replaces c's encoding/json Encoder and
+// Decoder with ones configured by h's options.
+func (h *handler) codec(c codec) codec {
+	if _, ok := c.enc.(jsonCodec); ok {
+		c.enc = h.jsonEnc
+	}
+	if _, ok := c.dec.(jsonCodec); ok {
+		c.dec = h.jsonDec
+	}
+	return c
+}
+
+// requestTimeout returns the request's timeout, the shorter
+// of the [Timeout] option and the [TimeoutHeader]'s value.
+func (h *handler) requestTimeout(r *http.Request) (time.Duration, error) {
+	if h.timeoutHeader == "" {
+		return h.timeout, nil
+	}
+	v := r.Header.Get(h.timeoutHeader)
+	if v == "" {
+		return h.timeout, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d <= 0 {
+		if h.strictTimeoutHeader {
+			return 0, Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid %s header %q", h.timeoutHeader, v),
+			}
+		}
+		return h.timeout, nil
+	}
+	if h.timeout > 0 && h.timeout < d {
+		return h.timeout, nil
+	}
+	return d, nil
+}
+
+// run decodes the request and calls the wrapped function.
+// A panic in either is recovered and returned as an error
+// unless the [RecoverPanics] option turned recovery off.
+func (h *handler) run(ctx context.Context, w http.ResponseWriter, r *http.Request) (resp any, err error) {
+	defer func() {
+		if !h.recoverPanics {
+			return
+		}
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		if h.onPanic != nil {
+			err = h.onPanic(ctx, v, debug.Stack())
+			return
+		}
+		err = PanicError{Value: v, Stack: debug.Stack()}
+	}()
+
+	var req any
+	if h.newReq != nil {
+		req, err = h.decode(w, r, ctx.Value(stateKey).(*state))
+		if err != nil {
+			return nil, asDecodeError(err)
+		}
+	}
+	resp, err = h.call(ctx, req)
+	h.putReq(req)
+	return resp, err
+}
+
+// getReq returns a zero request value,
+// reusing one from h.reqs when it can.
+func (h *handler) getReq() any {
+	if h.resetReq != nil {
+		if req := h.reqs.Get(); req != nil {
+			return req
+		}
+	}
+	return h.newReq()
+}
+
+// putReq zeroes req for reuse. The wrapped function only gets
+// a copy of it, so nothing refers to it once the call returns.
+func (h *handler) putReq(req any) {
+	if req == nil || h.resetReq == nil {
 		return
 	}
+	h.resetReq(req)
+	h.reqs.Put(req)
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(ret[0].Interface())
+// decode returns a new request value populated from r.
+// The body is decoded first, then headers, cookies, query parameters
+// and path parameters are bound in that order. So a field tagged with
+// path always gets the value from the URL even when the body sets it.
+// A [Binder]'s Bind method is called after them.
+// GET and HEAD requests without a body only use the URL.
+//
+// gzip and deflate Content-Encodings are decompressed.
+//
+// application/x-www-form-urlencoded and multipart/form-data
+// bodies are parsed as forms instead of being decoded.
+// See [bindForm] and [bindMultipart].
+//
+// With the [TeeBody] option the body is kept in st.
+func (h *handler) decode(w http.ResponseWriter, r *http.Request, st *state) (any, error) {
+	req := h.getReq()
+	hasBody := r.ContentLength != 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead)
+	if hasBody && !h.paramsOnly {
+		if h.requireContentLength && !hasContentLength(r) {
+			return nil, Error{
+				Code:    http.StatusLengthRequired,
+				Message: "Content-Length required",
+			}
+		}
+		if h.maxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+		}
+		decompressed, err := decompress(r)
+		if err != nil {
+			return nil, err
+		}
+		if decompressed && h.maxBodyBytes > 0 {
+			// limit the decompressed body too
+			r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+		}
+		if h.teeBody {
+			limit := h.maxBodyBytes
+			if limit <= 0 {
+				limit = MaxTeeBytes
+			}
+			st.rawBody = &limitedBuffer{n: limit}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, st.rawBody), r.Body}
+		}
+		contentType := r.Header.Get("Content-Type")
+		if h.requireContentType && !h.supportedContentType(contentType) {
+			return nil, Error{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: fmt.Sprintf("unsupported Content-Type %q", contentType),
+			}
+		}
+		switch mediaType(contentType) {
+		case "application/x-www-form-urlencoded":
+			if err := r.ParseForm(); err != nil {
+				return nil, decodeError(err)
+			}
+			if err := bindForm(req, r.PostForm); err != nil {
+				return nil, err
+			}
+		case "multipart/form-data":
+			if err := r.ParseMultipartForm(h.maxMemory); err != nil {
+				return nil, decodeError(err)
+			}
+			if err := bindMultipart(req, r.MultipartForm); err != nil {
+				return nil, err
+			}
+		default:
+			var (
+				c    = h.requestCodec(contentType)
+				body = io.Reader(r.Body)
+				raw  *bytes.Buffer
+			)
+			// encoding/json copies what it decodes, other
+			// decoders may keep references to the buffer
+			_, reuse := c.dec.(jsonCodec)
+			switch {
+			case h.schema != nil && isJSON(c):
+				raw = h.getBuffer(reuse)
+				defer h.putBuffer(raw, reuse)
+				if _, err := raw.ReadFrom(r.Body); err != nil {
+					return nil, decodeError(err)
+				}
+				if err := h.schema.check(raw.Bytes()); err != nil {
+					return nil, h.invalid(err)
+				}
+				body = bytes.NewReader(raw.Bytes())
+			case h.checkRequired && isJSON(c):
+				raw = h.getBuffer(reuse)
+				defer h.putBuffer(raw, reuse)
+				body = io.TeeReader(r.Body, raw)
+			}
+			if h.formatTimes && isJSON(c) {
+				data, err := io.ReadAll(body)
+				if err != nil {
+					return nil, decodeError(err)
+				}
+				if data, err = formatTimes(h.reqType, data); err != nil {
+					return nil, h.invalid(err)
+				}
+				body = bytes.NewReader(data)
+			}
+			err := c.dec.Decode(body, req)
+			if h.allowEmptyBody && errors.Is(err, io.EOF) {
+				// an empty body; truncated ones are io.ErrUnexpectedEOF
+				err = nil
+			}
+			if err != nil {
+				if isSemantic(err) {
+					return nil, h.invalid(decodeError(err))
+				}
+				return nil, decodeError(err)
+			}
+			if h.checkRequired && raw != nil {
+				if err := checkRequired(h.reqType, raw.Bytes()); err != nil {
+					return nil, h.invalid(err)
+				}
+			}
+		}
+	}
+	if h.bindHeader {
+		if err := bindHeader(req, r); err != nil {
+			return nil, err
+		}
+	}
+	if h.bindCookie {
+		if err := bindCookie(req, r); err != nil {
+			return nil, err
+		}
+	}
+	if h.bindQuery {
+		if err := bindQuery(req, r); err != nil {
+			return nil, err
+		}
+	}
+	if h.bindPath {
+		if err := bindPath(req, r); err != nil {
+			return nil, err
+		}
+	}
+	if h.bind != nil {
+		if err := h.bind(req, r); err != nil {
+			if _, ok := clientError(err); !ok {
+				err = Error{Code: http.StatusBadRequest, Message: err.Error()}
+			}
+			return nil, err
+		}
+	}
+	if err := h.check(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// hasContentLength reports whether r has a Content-Length
+// or is a request whose method doesn't need one.
+func hasContentLength(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength > 0 || (r.ContentLength == 0 && r.Header.Get("Content-Length") != "")
+	}
+	return true
+}
+
+// check applies default tags to a decoded request
+// and validates it.
+func (h *handler) check(req any) error {
+	if h.setDefaults {
+		if err := setDefaults(reflect.ValueOf(req)); err != nil {
+			return err
+		}
+	}
+	if h.validate != nil {
+		if err := h.validate(req); err != nil {
+			if _, ok := clientError(err); !ok {
+				err = h.invalid(Error{Code: http.StatusBadRequest, Message: err.Error()})
+			}
+			return err
+		}
+	}
+	return nil
 }
@@ -1,11 +1,19 @@
 package jh
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandlers(t *testing.T) {
@@ -57,3 +65,1017 @@ func TestErrHandler(t *testing.T) {
 		t.Errorf("got %d want %d", gotstatus, wantstatus)
 	}
 }
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{Error{Code: 404, Message: "no user", Kind: "user_not_found"}, 404, `{"message":"no user","kind":"user_not_found"}`},
+		{StatusError{Code: 409, Err: Error{Code: 400, Message: "taken", Kind: "email_taken"}}, 409, `{"message":"taken","kind":"email_taken"}`},
+		{NotFound("n"), 404, `{"message":"n"}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		ErrHandler(context.Background(), rec, c.err)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: got %d want %d", c.err, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%v: got = %s; want %s", c.err, got, c.wantBody)
+		}
+	}
+}
+
+func TestDefaultError(t *testing.T) {
+	defer func(code int, msg string) {
+		DefaultErrorStatus, DefaultErrorMessage = code, msg
+	}(DefaultErrorStatus, DefaultErrorMessage)
+	DefaultErrorStatus, DefaultErrorMessage = 503, "internal error"
+
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{errors.New("dial tcp 10.0.0.1:5432: refused"), 503, `{"error":"internal error"}`},
+		{NotFound("n"), 404, `{"message":"n"}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		ErrHandler(context.Background(), rec, c.err)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: got %d want %d", c.err, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%v: got = %s; want %s", c.err, got, c.wantBody)
+		}
+	}
+}
+
+func TestNilErrFunc(t *testing.T) {
+	notFound, err := Handler(func(ctx context.Context) error {
+		return NotFound("no widget")
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		h    http.Handler
+		req  *http.Request
+		want int
+	}{
+		{notFound, httptest.NewRequest("GET", "/", nil), 404},
+		{HandlerFunc(echoPoint, ErrFunc(nil)), httptest.NewRequest("POST", "/", strings.NewReader("{")), 400},
+	}
+	for i, c := range cases {
+		rec := httptest.NewRecorder()
+		c.h.ServeHTTP(rec, c.req)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ErrHandler(context.Background(), rec, Error{
+		Code:    422,
+		Message: "invalid",
+		Details: map[string]any{"name": "required"},
+	})
+	want := `{"message":"invalid","details":{"name":"required"}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	cases := []struct {
+		err  error
+		want Error
+	}{
+		{BadRequest("b"), Error{Code: 400, Message: "b"}},
+		{NotFound("n"), Error{Code: 404, Message: "n"}},
+		{Conflict("c"), Error{Code: 409, Message: "c"}},
+		{Errorf(429, "retry in %ds", 5), Error{Code: 429, Message: "retry in 5s"}},
+		{fmt.Errorf("wrapped: %w", NotFound("n")), Error{Code: 404, Message: "n"}},
+	}
+	for _, c := range cases {
+		var got Error
+		if !errors.As(c.err, &got) {
+			t.Errorf("%v: not an Error", c.err)
+			continue
+		}
+		if got.Code != c.want.Code || got.Message != c.want.Message {
+			t.Errorf("got %+v want %+v", got, c.want)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	var (
+		errUpstream = errors.New("dial tcp 10.0.0.7:443: connection refused")
+		err         = fmt.Errorf("fetching rates: %w", StatusError{Code: 502, Err: errUpstream})
+	)
+	var se StatusError
+	if !errors.As(err, &se) || se.Code != 502 {
+		t.Errorf("errors.As: got %+v", se)
+	}
+	if !errors.Is(err, errUpstream) {
+		t.Error("errors.Is didn't reach the wrapped error")
+	}
+
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{err, 502, `{"message":"Bad Gateway"}`},
+		{StatusError{Code: 503, Err: fmt.Errorf("rates: %w", Errorf(429, "slow down"))}, 503, `{"message":"slow down"}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		ErrHandler(context.Background(), rec, c.err)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: got %d want %d", c.err, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%v: got = %s; want %s", c.err, got, c.wantBody)
+		}
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	defer func(f func(Error, error) any) { ErrorBody = f }(ErrorBody)
+	ErrorBody = func(e Error, err error) any {
+		return map[string]any{"error": map[string]any{"message": e.Message, "status": e.Code}}
+	}
+	errGone := errors.New("gone")
+	defer func(s []registeredError) { registeredErrors.s = s }(registeredErrors.s)
+	RegisterError(errGone, 410)
+
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{NotFound("no widget"), 404, `{"error":{"message":"no widget","status":404}}`},
+		{errGone, 410, `{"error":{"message":"gone","status":410}}`},
+		{errors.New("boom"), 500, `{"error":{"message":"boom","status":500}}`},
+		{StatusError{Code: 502, Err: errors.New("refused")}, 502, `{"error":{"message":"Bad Gateway","status":502}}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		ErrHandler(context.Background(), rec, c.err)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: got %d want %d", c.err, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%v: got = %s; want %s", c.err, got, c.wantBody)
+		}
+	}
+}
+
+func TestRegisterError(t *testing.T) {
+	var (
+		errNotFound = errors.New("not found")
+		errConflict = errors.New("conflict")
+	)
+	defer func(s []registeredError) { registeredErrors.s = s }(registeredErrors.s)
+	RegisterError(errNotFound, 404)
+	RegisterError(errConflict, 400)
+	RegisterError(errConflict, 409)
+
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{errNotFound, 404, `{"message":"not found"}`},
+		{fmt.Errorf("widget 7: %w", errNotFound), 404, `{"message":"widget 7: not found"}`},
+		{errConflict, 409, `{"message":"conflict"}`},
+		{Error{Code: 422, Message: "m"}, 422, `{"message":"m"}`},
+		{errors.New("other"), 500, `{"error":"other"}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		ErrHandler(context.Background(), rec, c.err)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: got %d want %d", c.err, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%v: got = %s; want %s", c.err, got, c.wantBody)
+		}
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	type addReq struct {
+		X, Y int
+	}
+	type addResp struct {
+		Sum int
+	}
+	add := func(ctx context.Context, r addReq) (*addResp, error) {
+		return &addResp{r.X + r.Y}, nil
+	}
+
+	var (
+		body = strings.NewReader(`{"X": 1, "Y": 1}`)
+		r    = httptest.NewRequest("POST", "/", body)
+		rec  = httptest.NewRecorder()
+	)
+	HandlerFunc(add).ServeHTTP(rec, r)
+
+	got, _ := ioutil.ReadAll(rec.Result().Body)
+	want := "{\"Sum\":2}\n"
+	if string(got) != want {
+		t.Errorf("got = %q; want %q", got, want)
+	}
+
+	var (
+		badbody = strings.NewReader(`{"X": "1"}`)
+		badr    = httptest.NewRequest("POST", "/", badbody)
+		badrec  = httptest.NewRecorder()
+	)
+	HandlerFunc(add).ServeHTTP(badrec, badr)
+	if badrec.Code != 400 {
+		t.Errorf("got %d want %d", badrec.Code, 400)
+	}
+}
+
+type positive struct{ N int }
+
+func (p positive) Validate() error {
+	if p.N <= 0 {
+		return errors.New("n must be positive")
+	}
+	return nil
+}
+
+type even struct{ N int }
+
+func (e *even) Validate() error {
+	if e.N%2 != 0 {
+		return Error{Code: 422, Message: "n must be even"}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	echo := func(ctx context.Context, p positive) (positive, error) {
+		return p, nil
+	}
+	echoPtr := func(ctx context.Context, p *positive) (*positive, error) {
+		return p, nil
+	}
+	echoEven := func(ctx context.Context, e even) (even, error) {
+		return e, nil
+	}
+	cases := []struct {
+		f    any
+		body string
+		want int
+	}{
+		{echo, `{"N": 1}`, 200},
+		{echo, `{"N": 0}`, 400},
+		{echoPtr, `{"N": 1}`, 200},
+		{echoPtr, `{"N": 0}`, 400},
+		{echoEven, `{"N": 2}`, 200},
+		{echoEven, `{"N": 1}`, 422},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(c.f, ErrHandler)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("%T %s: got %d want %d", c.f, c.body, rec.Code, c.want)
+		}
+	}
+
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader(`{"N": 3}`))
+		rec = httptest.NewRecorder()
+	)
+	HandlerFunc(func(ctx context.Context, e even) (*even, error) {
+		return &e, nil
+	}).ServeHTTP(rec, r)
+	if rec.Code != 422 {
+		t.Errorf("got %d want %d", rec.Code, 422)
+	}
+}
+
+type created struct {
+	ID int
+}
+
+func (created) StatusCode() int { return 201 }
+
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		f    any
+		want int
+	}{
+		{func(ctx context.Context) (*struct{}, error) {
+			return &struct{}{}, nil
+		}, 200},
+		{func(ctx context.Context) (*created, error) {
+			return &created{}, nil
+		}, 201},
+		{func(ctx context.Context) (created, error) {
+			WithStatus(ctx, 202)
+			return created{}, nil
+		}, 202},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(c.f, ErrHandler)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestCreated(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, p point) (*point, error) {
+		Created(ctx, fmt.Sprintf("/points/%d", p.X))
+		if p.Y < 0 {
+			return nil, BadRequest("negative")
+		}
+		return &p, nil
+	})
+	cases := []struct {
+		body         string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{`{"x":1,"y":2}`, 201, "/points/1", `{"x":1,"y":2}`},
+		{`{"x":1,"y":-2}`, 400, "", `{"message":"negative"}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/points", strings.NewReader(c.body)))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+		}
+		if got := rec.Header().Get("Location"); got != c.wantLocation {
+			t.Errorf("%s: got Location %q want %q", c.body, got, c.wantLocation)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.body, got, c.wantBody)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, r struct {
+		Code int    `query:"code"`
+		Fail bool   `query:"fail"`
+		To   string `query:"to"`
+	}) (*point, error) {
+		Redirect(ctx, r.To, r.Code)
+		if r.Fail {
+			return nil, BadRequest("nope")
+		}
+		return &point{X: 1}, nil
+	})
+	cases := []struct {
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"/old?code=302&to=/new", 302, "/new"},
+		{"/old?code=307&to=https://example.com/x", 307, "https://example.com/x"},
+		{"/old?code=308&to=/v2/old", 308, "/v2/old"},
+		{"/old?code=301&to=new", 301, "/new"},
+		{"/old?code=302&to=/new&fail=true", 400, ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", c.target, nil))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.target, rec.Code, c.wantStatus)
+		}
+		if got := rec.Header().Get("Location"); got != c.wantLocation {
+			t.Errorf("%s: got Location %q want %q", c.target, got, c.wantLocation)
+		}
+		if strings.Contains(rec.Body.String(), `"x"`) {
+			t.Errorf("%s: got body %s", c.target, rec.Body)
+		}
+	}
+}
+
+func TestNilResponse(t *testing.T) {
+	cases := []struct {
+		f    any
+		want int
+	}{
+		{func(ctx context.Context) (*struct{}, error) {
+			return nil, nil
+		}, 204},
+		{func(ctx context.Context) (*created, error) {
+			return nil, nil
+		}, 204},
+		{func(ctx context.Context) (any, error) {
+			return nil, nil
+		}, 204},
+		{func(ctx context.Context) (any, error) {
+			return (*created)(nil), nil
+		}, 204},
+		{func(ctx context.Context) (*struct{}, error) {
+			WithStatus(ctx, 200)
+			return nil, nil
+		}, 200},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(c.f, ErrHandler)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("case %d: got = %q; want empty body", i, rec.Body)
+		}
+	}
+}
+
+func TestPanic(t *testing.T) {
+	boom := func(ctx context.Context) (*struct{}, error) {
+		panic("boom")
+	}
+
+	var (
+		perr PanicError
+		r    = httptest.NewRequest("POST", "/", nil)
+		rec  = httptest.NewRecorder()
+	)
+	h, _ := Handler(boom, func(ctx context.Context, w http.ResponseWriter, err error) {
+		errors.As(err, &perr)
+		ErrHandler(ctx, w, err)
+	})
+	h.ServeHTTP(rec, r)
+	if rec.Code != 500 {
+		t.Errorf("got %d want %d", rec.Code, 500)
+	}
+	got, _ := ioutil.ReadAll(rec.Result().Body)
+	want := "{\"error\":\"jh: panic: boom\"}\n"
+	if string(got) != want {
+		t.Errorf("got = %q; want %q", got, want)
+	}
+	if perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Errorf("got %v, %d byte stack", perr.Value, len(perr.Stack))
+	}
+
+	rec = httptest.NewRecorder()
+	h, _ = Handler(boom, ErrHandler, OnPanic(func(ctx context.Context, v any, stack []byte) error {
+		return Error{Code: 503, Message: "try again"}
+	}))
+	h.ServeHTTP(rec, r)
+	if rec.Code != 503 {
+		t.Errorf("got %d want %d", rec.Code, 503)
+	}
+}
+
+func TestErrorOnly(t *testing.T) {
+	type delReq struct {
+		ID int
+	}
+	var deleted int
+	del := func(ctx context.Context, r delReq) error {
+		if r.ID == 0 {
+			return Error{Code: 404, Message: "not found"}
+		}
+		deleted = r.ID
+		return nil
+	}
+	h, err := Handler(del, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		r   = httptest.NewRequest("DELETE", "/", strings.NewReader(`{"ID": 3}`))
+		rec = httptest.NewRecorder()
+	)
+	h.ServeHTTP(rec, r)
+	if rec.Code != 204 || rec.Body.Len() != 0 || deleted != 3 {
+		t.Errorf("got %d %q %d want 204", rec.Code, rec.Body, deleted)
+	}
+
+	r = httptest.NewRequest("DELETE", "/", strings.NewReader(`{}`))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != 404 {
+		t.Errorf("got %d want 404", rec.Code)
+	}
+
+	h, _ = Handler(func(ctx context.Context) error { return nil }, ErrHandler)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != 204 {
+		t.Errorf("got %d want 204", rec.Code)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	type order struct {
+		ID      int               `json:"id"`
+		Price   float64           `json:"price"`
+		Paid    bool              `json:"paid"`
+		Items   []string          `json:"items"`
+		Created time.Time         `json:"created"`
+		Meta    map[string]string `json:"meta"`
+		Ship    struct {
+			City string `json:"city"`
+		} `json:"ship"`
+	}
+	h, _ := Handler(func(ctx context.Context, o order) error {
+		return nil
+	}, ErrHandler)
+
+	cases := []struct {
+		body, want string
+	}{
+		{`{"id":"7"}`, `field "id" must be an integer, not a string`},
+		{`{"id":1.5}`, `field "id" must be an integer, not a number`},
+		{`{"price":true}`, `field "price" must be a number, not a boolean`},
+		{`{"paid":"yes"}`, `field "paid" must be a boolean, not a string`},
+		{`{"items":"a"}`, `field "items" must be an array, not a string`},
+		{`{"created":1}`, `field "created" must be a string, not a number`},
+		{`{"meta":[]}`, `field "meta" must be an object, not an array`},
+		{`{"ship":{"city":1}}`, `field "ship.city" must be a string, not a number`},
+		{`[1]`, `request body must be an object, not an array`},
+		{`{"id":x}`, `malformed JSON at byte 7`},
+		{`{"id":1`, `malformed JSON: body ends unexpectedly`},
+		{``, `request body is empty`},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if rec.Code != 400 {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, 400)
+		}
+		var got Error
+		json.NewDecoder(rec.Body).Decode(&got)
+		if got.Message != c.want {
+			t.Errorf("%s: got %q want %q", c.body, got.Message, c.want)
+		}
+	}
+}
+
+func TestHandlerSignature(t *testing.T) {
+	cases := []struct {
+		f    any
+		want error
+	}{
+		{func() error { return nil }, ErrTooFewArgs},
+		{func(context.Context, int, int) error { return nil }, ErrTooManyArgs},
+		{func(int) error { return nil }, ErrMissingCtx},
+		{func(context.Context) {}, ErrNumRet},
+		{func(context.Context) (int, int, error) { return 0, 0, nil }, ErrNumRet},
+		{func(context.Context) int { return 0 }, ErrMissingErr},
+		{func(context.Context) (int, int) { return 0, 0 }, ErrMissingErr},
+		{func(context.Context) error { return nil }, nil},
+		{func(context.Context, int) (int, error) { return 0, nil }, nil},
+		{func(context.Context) (chan int, error) { return nil, nil }, ErrRespType},
+		{func(context.Context) (func(), error) { return nil, nil }, ErrRespType},
+		{func(context.Context) (complex128, error) { return 0, nil }, ErrRespType},
+	}
+	for _, c := range cases {
+		_, err := Handler(c.f, ErrHandler)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%T: got %v want %v", c.f, err, c.want)
+		}
+		if err == nil {
+			continue
+		}
+		var se *SignatureError
+		if !errors.As(err, &se) {
+			t.Fatalf("%T: got %T want *SignatureError", c.f, err)
+		}
+		if want := fmt.Sprintf("%T", c.f); se.Func != want {
+			t.Errorf("got func %q want %q", se.Func, want)
+		}
+	}
+
+	_, err := Handler(func(int) error { return nil }, ErrHandler)
+	if want := "jh: handler: func(int) error: arg 1 is int, want context.Context"; err == nil || err.Error() != want {
+		t.Errorf("got %v want %q", err, want)
+	}
+}
+
+func TestResponseShapes(t *testing.T) {
+	type item struct {
+		N int `json:"n"`
+	}
+	cases := []struct {
+		f    any
+		want string
+	}{
+		{func(context.Context) ([]item, error) { return []item{{1}, {2}}, nil }, `[{"n":1},{"n":2}]`},
+		{func(context.Context) ([]int, error) { return nil, nil }, `null`},
+		{func(context.Context) ([]int, error) { return []int{}, nil }, `[]`},
+		{func(context.Context) (map[string]int, error) { return map[string]int{"a": 1}, nil }, `{"a":1}`},
+		{func(context.Context) (item, error) { return item{3}, nil }, `{"n":3}`},
+		{func(context.Context) (string, error) { return "hi", nil }, `"hi"`},
+		{func(context.Context) (int, error) { return 7, nil }, `7`},
+		{func(context.Context) (bool, error) { return true, nil }, `true`},
+		{func(context.Context) (any, error) { return []string{"x"}, nil }, `["x"]`},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h, err := Handler(c.f, ErrHandler)
+		if err != nil {
+			t.Fatalf("%T: %v", c.f, err)
+		}
+		h.ServeHTTP(rec, r)
+		if got := strings.TrimSpace(rec.Body.String()); got != c.want {
+			t.Errorf("%T: got = %s; want %s", c.f, got, c.want)
+		}
+	}
+}
+
+func TestReaderResponse(t *testing.T) {
+	export := func(ctx context.Context) (io.Reader, error) {
+		ResponseWriter(ctx).Header().Set("Content-Type", "text/csv")
+		return strings.NewReader("a,b\n1,2\n"), nil
+	}
+	var (
+		r   = httptest.NewRequest("GET", "/", nil)
+		rec = httptest.NewRecorder()
+	)
+	h, _ := Handler(export, ErrHandler)
+	h.ServeHTTP(rec, r)
+	if got := rec.Body.String(); got != "a,b\n1,2\n" {
+		t.Errorf("got = %q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("got = %q; want %q", ct, "text/csv")
+	}
+
+	rec = httptest.NewRecorder()
+	HandlerFunc(func(ctx context.Context, _ struct{}) (*strings.Reader, error) {
+		return strings.NewReader("raw"), nil
+	}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Body.String(); got != "raw" {
+		t.Errorf("got = %q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("got = %q; want %q", ct, "application/octet-stream")
+	}
+}
+
+type requestContext struct {
+	context.Context
+}
+
+func (rc requestContext) User() string {
+	u, _ := rc.Value(ctxKey("user")).(string)
+	return u
+}
+
+type userContext interface {
+	context.Context
+	User() string
+}
+
+func TestContextArg(t *testing.T) {
+	withUser := WithValue(ctxKey("user"), "ann")
+	cases := []struct {
+		f    any
+		want string
+	}{
+		{func(rc requestContext) (*string, error) {
+			u := rc.User()
+			return &u, nil
+		}, `"ann"`},
+		{func(rc *requestContext) (*string, error) {
+			u := rc.User()
+			return &u, nil
+		}, `"ann"`},
+		{func(rc requestContext, p point) (*point, error) {
+			if Request(rc) == nil {
+				return nil, errors.New("no request")
+			}
+			return &p, nil
+		}, `{"x":1,"y":2}`},
+	}
+	for i, c := range cases {
+		h, err := Handler(c.f, ErrHandler)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		rec := httptest.NewRecorder()
+		withUser(h).ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"X":1,"Y":2}`)))
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != 200 || got != c.want {
+			t.Errorf("case %d: got %d %s want 200 %s", i, rec.Code, got, c.want)
+		}
+	}
+
+	h, err := Handler(func(uc userContext) error { return nil }, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 500 {
+		t.Errorf("got %d want 500", rec.Code)
+	}
+
+	// implements context.Context without embedding it
+	type indirect struct{ *requestContext }
+	if _, err := Handler(func(indirect) error { return nil }, ErrHandler); !errors.Is(err, ErrMissingCtx) {
+		t.Errorf("got %v want %v", err, ErrMissingCtx)
+	}
+}
+
+func TestHandlerResponse(t *testing.T) {
+	files := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("file " + r.URL.Path))
+	})
+	type download struct {
+		Raw bool `query:"raw"`
+	}
+	h, err := Handler(func(ctx context.Context, r download) (http.Handler, error) {
+		if r.Raw {
+			return files, nil
+		}
+		return nil, nil
+	}, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/a.txt?raw=true", nil))
+	if got := rec.Body.String(); rec.Code != 200 || got != "file /a.txt" {
+		t.Errorf("got %d %q", rec.Code, got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("got = %q; want %q", ct, "text/plain")
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/a.txt", nil))
+	if rec.Code != 204 {
+		t.Errorf("got %d want 204", rec.Code)
+	}
+
+	fn, err := Handler(func(ctx context.Context) (http.HandlerFunc, error) {
+		return nil, nil
+	}, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	fn.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 204 {
+		t.Errorf("got %d want 204", rec.Code)
+	}
+}
+
+func TestDecodeErrorType(t *testing.T) {
+	var got error
+	record := ErrFunc(func(ctx context.Context, w http.ResponseWriter, err error) {
+		got = err
+		ErrHandler(ctx, w, err)
+	})
+	h := HandlerFunc(func(ctx context.Context, p putWidget) (*point, error) {
+		if p.Name == "taken" {
+			return nil, Conflict("name taken")
+		}
+		return &point{}, nil
+	}, record)
+	cases := []struct {
+		body      string
+		status    int
+		decode    bool
+		syntaxErr bool
+	}{
+		{`{"name":`, 400, true, false},
+		{`{x}`, 400, true, true},
+		{`{}`, 400, true, false},
+		{`{"name":"taken"}`, 409, false, false},
+	}
+	for _, c := range cases {
+		got = nil
+		r := httptest.NewRequest("PUT", "/widgets/1", strings.NewReader(c.body))
+		r.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.status {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.status)
+		}
+		var de DecodeError
+		if errors.As(got, &de) != c.decode {
+			t.Errorf("%s: got %T, DecodeError %v", c.body, got, !c.decode)
+		}
+		var se *json.SyntaxError
+		if errors.As(got, &se) != c.syntaxErr {
+			t.Errorf("%s: got %v, *json.SyntaxError %v", c.body, got, !c.syntaxErr)
+		}
+		var jhe Error
+		if !errors.As(got, &jhe) || jhe.Code != c.status {
+			t.Errorf("%s: got %v want an Error with Code %d", c.body, got, c.status)
+		}
+	}
+}
+
+type precomputed struct {
+	data []byte
+}
+
+func (p precomputed) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(p.data)
+	return int64(n), err
+}
+
+func TestWriterToResponse(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, _ struct{}) (*precomputed, error) {
+		return &precomputed{[]byte("a,b\n")}, nil
+	}, BeforeWrite(func(ctx context.Context, w http.ResponseWriter, resp any) error {
+		w.Header().Set("Content-Type", "text/csv")
+		return nil
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Body.String(); got != "a,b\n" {
+		t.Errorf("got = %q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("got = %q; want %q", ct, "text/csv")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk failed")
+}
+
+func TestStreamErrorTrailer(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context) (io.Reader, error) {
+		return io.MultiReader(strings.NewReader("a,b\n"), failingReader{}), nil
+	}, ErrHandler)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	res := rec.Result()
+	if res.StatusCode != 200 || rec.Body.String() != "a,b\n" {
+		t.Errorf("got %d %q", res.StatusCode, rec.Body)
+	}
+	if got := res.Trailer.Get(StreamErrorTrailer); got != "disk failed" {
+		t.Errorf("got trailer %q want %q", got, "disk failed")
+	}
+
+	defer func(msg string) { DefaultErrorMessage = msg }(DefaultErrorMessage)
+	DefaultErrorMessage = "internal error"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Result().Trailer.Get(StreamErrorTrailer); got != "internal error" {
+		t.Errorf("got trailer %q want %q", got, "internal error")
+	}
+}
+
+// countingCloser counts the calls of Close.
+type countingCloser struct {
+	io.Reader
+	closed int
+}
+
+func (c *countingCloser) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestReadCloserResponse(t *testing.T) {
+	cases := []struct {
+		r          io.Reader
+		fail       error
+		opts       []Option
+		wantStatus int
+		wantClosed int
+	}{
+		{strings.NewReader("a,b\n"), nil, nil, 200, 1},
+		{failingReader{}, nil, nil, 200, 1},
+		{strings.NewReader("a,b\n"), nil, []Option{BeforeWrite(func(ctx context.Context, w http.ResponseWriter, resp any) error {
+			return Conflict("stale")
+		})}, 409, 1},
+		{strings.NewReader("a,b\n"), NotFound("gone"), nil, 404, 0},
+	}
+	for i, c := range cases {
+		rc := &countingCloser{Reader: c.r}
+		h, _ := Handler(func(ctx context.Context) (io.ReadCloser, error) {
+			return rc, c.fail
+		}, ErrHandler, c.opts...)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if rc.closed != c.wantClosed {
+			t.Errorf("case %d: closed %d times want %d", i, rc.closed, c.wantClosed)
+		}
+	}
+}
+
+func TestRequestReuse(t *testing.T) {
+	type note struct {
+		Title string `json:"title"`
+		Tags  []int  `json:"tags"`
+	}
+	echo := func(ctx context.Context, n note) (*note, error) {
+		return &n, nil
+	}
+	reflected, _ := Handler(echo, ErrHandler)
+	for _, h := range []http.Handler{reflected, HandlerFunc(echo)} {
+		for _, c := range []struct{ body, want string }{
+			{`{"title":"a","tags":[1,2]}`, `{"title":"a","tags":[1,2]}`},
+			{`{"tags":[3]}`, `{"title":"","tags":[3]}`},
+			{`{}`, `{"title":"","tags":null}`},
+		} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+			if got := strings.TrimSpace(rec.Body.String()); got != c.want {
+				t.Errorf("%T %s: got %s want %s", h, c.body, got, c.want)
+			}
+		}
+	}
+}
+
+func BenchmarkHandler(b *testing.B) {
+	reflected, _ := Handler(echoPoint, ErrHandler)
+	reflectedPointer, _ := Handler(func(ctx context.Context, p *point) (*point, error) {
+		return p, nil
+	}, ErrHandler)
+	benchmarks := []struct {
+		name string
+		h    http.Handler
+	}{
+		{"Handler", reflected},
+		{"HandlerPointerReq", reflectedPointer},
+		{"HandlerFunc", HandlerFunc(echoPoint)},
+		{"HandlerFuncPointerReq", HandlerFunc(func(ctx context.Context, p *point) (*point, error) {
+			return p, nil
+		})},
+	}
+	body := []byte(`{"x":1,"y":2}`)
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			r := httptest.NewRequest("POST", "/", nil)
+			for i := 0; i < b.N; i++ {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				bm.h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
+
+func TestWritten(t *testing.T) {
+	type entry struct {
+		status int
+		n      int64
+	}
+	var got []entry
+	SetLogger(func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error) {
+		s, n := Written(ctx)
+		got = append(got, entry{s, n})
+	})
+	defer SetLogger(nil)
+
+	h := HandlerFunc(func(ctx context.Context, r struct{ Fail bool }) (*string, error) {
+		if r.Fail {
+			return nil, Conflict("taken")
+		}
+		resp := "hello"
+		return &resp, nil
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"Fail":true}`)))
+	want := []entry{{200, int64(len("\"hello\"\n"))}, {409, int64(len(`{"message":"taken"}` + "\n"))}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if s, n := Written(context.Background()); s != 0 || n != 0 {
+		t.Errorf("got %d %d want 0 0", s, n)
+	}
+}
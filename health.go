@@ -0,0 +1,86 @@
+package jh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthTimeout bounds the time a [Health] handler waits for
+// its checks. The request's own deadline applies when it's sooner.
+var HealthTimeout = 5 * time.Second
+
+// Health returns a handler for health and readiness endpoints
+// that runs the named checks concurrently and reports each
+// one's status by name:
+//
+//	http.Handle("GET /readyz", jh.Health(map[string]func(context.Context) error{
+//		"db":    db.PingContext,
+//		"cache": cache.Ping,
+//	}))
+//
+// It responds with a 200 when every check returns nil and
+// with a 503 when any fails, times out or panics:
+//
+//	{"status":"fail","checks":{"cache":{"status":"fail","error":"context deadline exceeded"},"db":{"status":"ok"}}}
+//
+// A failed check's error is reported like [ErrHandler] sends it,
+// so [DefaultErrorMessage] keeps its text from clients.
+// Checks get a context that's done after [HealthTimeout].
+// Without checks it always responds with a 200, which suits
+// liveness endpoints.
+func Health(checks map[string]func(ctx context.Context) error) http.Handler {
+	// without a request, bodies and their headers are ignored
+	h, err := Handler(func(ctx context.Context) (*healthReport, error) {
+		cctx, cancel := context.WithTimeout(ctx, HealthTimeout)
+		defer cancel()
+		done := make(map[string]chan error, len(checks))
+		for name, check := range checks {
+			c := make(chan error, 1)
+			done[name] = c
+			go func() {
+				defer func() {
+					if v := recover(); v != nil {
+						c <- fmt.Errorf("panic: %v", v)
+					}
+				}()
+				c <- check(cctx)
+			}()
+		}
+
+		report := &healthReport{Status: "ok", Checks: make(map[string]checkResult, len(checks))}
+		for name, c := range done {
+			var err error
+			select {
+			case err = <-c:
+			case <-cctx.Done():
+				err = cctx.Err()
+			}
+			res := checkResult{Status: "ok"}
+			if err != nil {
+				report.Status = "fail"
+				res = checkResult{Status: "fail", Error: ErrorOf(err).Message}
+			}
+			report.Checks[name] = res
+		}
+		if report.Status != "ok" {
+			WithStatus(ctx, http.StatusServiceUnavailable)
+		}
+		return report, nil
+	}, ErrHandler)
+	if err != nil {
+		panic(err) // the wrapped function's signature is fixed
+	}
+	return h
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
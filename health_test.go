@@ -0,0 +1,64 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	defer func(d time.Duration) { HealthTimeout = d }(HealthTimeout)
+	HealthTimeout = 10 * time.Millisecond
+
+	var (
+		ok   = func(ctx context.Context) error { return nil }
+		down = func(ctx context.Context) error { return errors.New("db down") }
+		hang = func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+		boom = func(ctx context.Context) error { panic("boom") }
+	)
+	cases := []struct {
+		checks     map[string]func(context.Context) error
+		wantStatus int
+		wantBody   string
+	}{
+		{nil, 200, `{"status":"ok","checks":{}}`},
+		{map[string]func(context.Context) error{"db": ok, "cache": ok}, 200, `{"status":"ok","checks":{"cache":{"status":"ok"},"db":{"status":"ok"}}}`},
+		{map[string]func(context.Context) error{"cache": ok, "db": down}, 503, `{"status":"fail","checks":{"cache":{"status":"ok"},"db":{"status":"fail","error":"db down"}}}`},
+		{map[string]func(context.Context) error{"queue": hang}, 503, `{"status":"fail","checks":{"queue":{"status":"fail","error":"context deadline exceeded"}}}`},
+		{map[string]func(context.Context) error{"search": boom}, 503, `{"status":"fail","checks":{"search":{"status":"fail","error":"panic: boom"}}}`},
+	}
+	for i, c := range cases {
+		rec := httptest.NewRecorder()
+		Health(c.checks).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+
+	// probes sending a body still get checked
+	r := httptest.NewRequest("GET", "/readyz", strings.NewReader("ping"))
+	r.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	Health(nil).ServeHTTP(rec, r)
+	if rec.Code != 200 {
+		t.Errorf("got %d want 200: %s", rec.Code, rec.Body)
+	}
+
+	defer func(msg string) { DefaultErrorMessage = msg }(DefaultErrorMessage)
+	DefaultErrorMessage = "internal error"
+	rec = httptest.NewRecorder()
+	Health(map[string]func(context.Context) error{"db": down}).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"status":"fail","checks":{"db":{"status":"fail","error":"internal error"}}}`; got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
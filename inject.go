@@ -0,0 +1,32 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+)
+
+// Inject returns middleware that replaces the request's context
+// with the one f returns, eg to add request scoped dependencies
+// that wrapped functions read from their context:
+//
+//	h := Chain(HandlerFunc(list), Inject(func(ctx context.Context) context.Context {
+//		return db.NewContext(ctx, pool)
+//	}))
+//
+// f runs before the wrapped handler so values it adds are in the
+// context passed to wrapped functions, error funcs and loggers.
+func Inject(f func(ctx context.Context) context.Context) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(f(r.Context())))
+		})
+	}
+}
+
+// WithValue returns middleware that adds key and
+// value to the request's context. See [Inject].
+func WithValue(key, value any) func(http.Handler) http.Handler {
+	return Inject(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key, value)
+	})
+}
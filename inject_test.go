@@ -0,0 +1,30 @@
+package jh
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type ctxKey string
+
+func TestInject(t *testing.T) {
+	h := Chain(HandlerFunc(func(ctx context.Context, p point) (*string, error) {
+		s := ctx.Value(ctxKey("db")).(string) + "," + ctx.Value(ctxKey("tenant")).(string)
+		return &s, nil
+	}),
+		WithValue(ctxKey("db"), "pool"),
+		Inject(func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, ctxKey("tenant"), "acme")
+		}),
+	)
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+		rec = httptest.NewRecorder()
+	)
+	h.ServeHTTP(rec, r)
+	if got, want := strings.TrimSpace(rec.Body.String()), `"pool,acme"`; got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
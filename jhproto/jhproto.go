@@ -0,0 +1,69 @@
+// Package jhproto registers a protocol buffers codec with jh.
+// Importing it makes handlers decode application/x-protobuf
+// request bodies and encode responses as protocol buffers
+// for requests that accept them:
+//
+//	import _ "github.com/ryandotsmith/jh/jhproto"
+//
+// The request and response types of the wrapped
+// functions must implement [proto.Message].
+package jhproto
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/ryandotsmith/jh"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type of protocol buffers.
+const ContentType = "application/x-protobuf"
+
+func init() {
+	jh.RegisterCodec(ContentType, jh.EncoderFunc(encode), jh.DecoderFunc(decode))
+}
+
+func encode(w io.Writer, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("jhproto: %T isn't a proto.Message", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func decode(r io.Reader, v any) error {
+	m, ok := message(v)
+	if !ok {
+		return fmt.Errorf("jhproto: %T isn't a proto.Message", v)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// message returns the proto.Message v points to. jh decodes into
+// a pointer to the request type so requests of type *T, where
+// *T is a proto.Message, arrive as **T and are allocated here.
+func message(v any) (proto.Message, bool) {
+	if m, ok := v.(proto.Message); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Pointer {
+		return nil, false
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	m, ok := rv.Elem().Interface().(proto.Message)
+	return m, ok
+}
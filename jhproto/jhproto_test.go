@@ -0,0 +1,62 @@
+package jhproto
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ryandotsmith/jh"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProto(t *testing.T) {
+	upper := func(ctx context.Context, s *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String(strings.ToUpper(s.GetValue())), nil
+	}
+	reflected, _ := jh.Handler(upper, jh.ErrHandler)
+	body, _ := proto.Marshal(wrapperspb.String("hi"))
+
+	for _, h := range []http.Handler{jh.HandlerFunc(upper), reflected} {
+		var (
+			r   = httptest.NewRequest("POST", "/", bytes.NewReader(body))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Content-Type", ContentType)
+		r.Header.Set("Accept", ContentType)
+		h.ServeHTTP(rec, r)
+		if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+			t.Errorf("got %q want %q", ct, ContentType)
+		}
+		var got wrapperspb.StringValue
+		if err := proto.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.GetValue() != "HI" {
+			t.Errorf("got %q want %q", got.GetValue(), "HI")
+		}
+	}
+
+	// JSON is still the default
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader(`"hi"`))
+		rec = httptest.NewRecorder()
+	)
+	jh.HandlerFunc(func(ctx context.Context, s string) (*string, error) {
+		return &s, nil
+	}).ServeHTTP(rec, r)
+	if got := strings.TrimSpace(rec.Body.String()); got != `"hi"` {
+		t.Errorf("got = %s", got)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader("junk"))
+	rec = httptest.NewRecorder()
+	r.Header.Set("Content-Type", ContentType)
+	jh.HandlerFunc(upper).ServeHTTP(rec, r)
+	if rec.Code != 400 {
+		t.Errorf("got %d want %d", rec.Code, 400)
+	}
+}
@@ -0,0 +1,96 @@
+// Package jhtest helps testing handlers returned by jh.
+//
+//	rec, err := jhtest.Serve(h, "POST", "/add", req{X: 1, Y: 2})
+//
+//	sum, _, err := jhtest.Call[resp](h, "POST", "/add", req{X: 1, Y: 2})
+//	if sum.Sum != 3 {
+//		t.Errorf("got %d want 3", sum.Sum)
+//	}
+package jhtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/ryandotsmith/jh"
+)
+
+// Serve serves a request to h and returns the recorded response.
+// reqBody is encoded as JSON unless it's nil, which sends no body,
+// or a []byte, string or io.Reader, which are sent as is. Requests
+// with a body have an application/json Content-Type. The error
+// is from encoding reqBody; responses of any status are returned.
+//
+// Handlers that use path values need to be served by
+// a [jh.Mux] or [http.ServeMux] to get them.
+func Serve(h http.Handler, method, path string, reqBody any) (*httptest.ResponseRecorder, error) {
+	var body io.Reader
+	switch b := reqBody.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(b)
+	case string:
+		body = strings.NewReader(b)
+	case io.Reader:
+		body = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("jhtest: encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	r := httptest.NewRequest(method, path, body)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec, nil
+}
+
+// Call is like [Serve] but also decodes a JSON response into a Resp.
+// Responses with a status of 400 or more return a [jh.Error]
+// with the status as its Code and the body's message and kind. A 204
+// No Content response returns Resp's zero value.
+func Call[Resp any](h http.Handler, method, path string, reqBody any) (Resp, *httptest.ResponseRecorder, error) {
+	var resp Resp
+	rec, err := Serve(h, method, path, reqBody)
+	if err != nil {
+		return resp, nil, err
+	}
+	if rec.Code >= 400 {
+		return resp, rec, responseError(rec)
+	}
+	if rec.Code == http.StatusNoContent || rec.Body.Len() == 0 {
+		return resp, rec, nil
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		return resp, rec, fmt.Errorf("jhtest: decoding %d response: %w", rec.Code, err)
+	}
+	return resp, rec, nil
+}
+
+// responseError returns the error that rec's body describes.
+// [jh.ErrHandler] writes [jh.Error]s with a message field
+// and other errors with an error field.
+func responseError(rec *httptest.ResponseRecorder) error {
+	var body struct {
+		Message string         `json:"message"`
+		Error   string         `json:"error"`
+		Kind    string         `json:"kind"`
+		Details map[string]any `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		body.Message = strings.TrimSpace(rec.Body.String())
+	}
+	if body.Message == "" {
+		body.Message = body.Error
+	}
+	return jh.Error{Code: rec.Code, Message: body.Message, Kind: body.Kind, Details: body.Details}
+}
@@ -0,0 +1,85 @@
+package jhtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ryandotsmith/jh"
+)
+
+type sum struct {
+	X, Y int
+}
+
+type total struct {
+	Sum int `json:"sum"`
+}
+
+func add(ctx context.Context, r sum) (*total, error) {
+	if r.X < 0 && r.Y < 0 {
+		return nil, jh.Error{Code: 422, Message: "negative", Kind: "negative_sum"}
+	}
+	if r.X < 0 {
+		return nil, jh.BadRequest("x must be positive")
+	}
+	if r.Y < 0 {
+		return nil, errors.New("boom")
+	}
+	return &total{r.X + r.Y}, nil
+}
+
+func TestServe(t *testing.T) {
+	h := jh.HandlerFunc(add)
+	for _, body := range []any{sum{1, 2}, `{"X":1,"Y":2}`, []byte(`{"X":1,"Y":2}`), strings.NewReader(`{"X":1,"Y":2}`)} {
+		rec, err := Serve(h, "POST", "/add", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rec.Body.String(); rec.Code != 200 || got != "{\"sum\":3}\n" {
+			t.Errorf("%T: got %d %q", body, rec.Code, got)
+		}
+	}
+	if _, err := Serve(h, "POST", "/add", func() {}); err == nil {
+		t.Error("expected error encoding a func")
+	}
+}
+
+func TestCall(t *testing.T) {
+	h := jh.HandlerFunc(add)
+	got, rec, err := Call[total](h, "POST", "/add", sum{1, 2})
+	if err != nil || got.Sum != 3 || rec.Code != 200 {
+		t.Errorf("got %v %v %v", got, rec.Code, err)
+	}
+
+	cases := []struct {
+		req  sum
+		want jh.Error
+	}{
+		{sum{-1, 0}, jh.Error{Code: 400, Message: "x must be positive"}},
+		{sum{0, -1}, jh.Error{Code: 500, Message: "boom"}},
+		{sum{-1, -1}, jh.Error{Code: 422, Message: "negative", Kind: "negative_sum"}},
+	}
+	for _, c := range cases {
+		_, _, err := Call[total](h, "POST", "/add", c.req)
+		var jhe jh.Error
+		if !errors.As(err, &jhe) || jhe.Code != c.want.Code || jhe.Message != c.want.Message || jhe.Kind != c.want.Kind {
+			t.Errorf("%v: got %v want %v", c.req, err, c.want)
+		}
+	}
+
+	del := jh.HandlerFunc(func(ctx context.Context, _ struct{}) (*total, error) {
+		return nil, nil
+	})
+	if got, rec, err := Call[*total](del, "DELETE", "/", struct{}{}); err != nil || got != nil || rec.Code != 204 {
+		t.Errorf("got %v %d %v", got, rec.Code, err)
+	}
+
+	raw := jh.HandlerFunc(func(ctx context.Context, _ struct{}) (*strings.Reader, error) {
+		return strings.NewReader("not json"), nil
+	})
+	if _, _, err := Call[total](raw, "GET", "/", nil); err == nil {
+		t.Error("expected error decoding a non JSON response")
+	}
+}
@@ -0,0 +1,107 @@
+// Package jhws serves WebSocket connections in the jh style
+// using github.com/gorilla/websocket:
+//
+//	http.Handle("/chat", jhws.Handler(nil, func(ctx context.Context, conn *websocket.Conn) error {
+//		for {
+//			_, msg, err := conn.ReadMessage()
+//			if err != nil {
+//				return err
+//			}
+//			log.Printf("%s: %s", jh.Request(ctx).RemoteAddr, msg)
+//		}
+//	}))
+package jhws
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/ryandotsmith/jh"
+)
+
+// Handler returns a handler that upgrades requests to WebSocket
+// connections using u and serves them with f. A nil u uses
+// a zero [websocket.Upgrader], which rejects cross-origin
+// requests. opts are applied as they are by [jh.Handler].
+//
+// The context passed to f is like the one passed to wrapped
+// functions, so [jh.Request] works in it. Requests that can't
+// be upgraded are passed to the error func as a [jh.Error]
+// with the status the upgrade failed with, eg 400 for a request
+// that isn't a WebSocket handshake. Errors that should reject
+// a request before the upgrade, like failed authentication,
+// belong in middleware such as [jh.Auth]. u's Error func
+// isn't used.
+//
+// Once the connection is upgraded there's no HTTP response to
+// write errors to. When f returns an error other than one
+// for a closed connection, the connection is closed
+// with a close frame holding the error's message.
+func Handler(u *websocket.Upgrader, f func(ctx context.Context, conn *websocket.Conn) error, opts ...jh.Option) http.Handler {
+	if u == nil {
+		u = &websocket.Upgrader{}
+	}
+	h, err := jh.Handler(func(ctx context.Context) (http.Handler, error) {
+		var (
+			up    = *u
+			upErr error
+			w     = jh.ResponseWriter(ctx)
+			r     = jh.Request(ctx)
+		)
+		up.Error = func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			w.Header().Set("Sec-Websocket-Version", "13")
+			upErr = jh.Error{Code: status, Message: reason.Error()}
+		}
+		conn, err := up.Upgrade(hijacker{w}, r, nil)
+		if err != nil {
+			if upErr == nil {
+				upErr = err
+			}
+			return nil, upErr
+		}
+		defer conn.Close()
+
+		err = f(ctx, conn)
+		var ce *websocket.CloseError
+		if err != nil && !errors.As(err, &ce) && !errors.Is(err, net.ErrClosed) {
+			msg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, closeReason(err))
+			conn.WriteMessage(websocket.CloseMessage, msg)
+		}
+		// the connection is hijacked, so nothing is left to write
+		return written, nil
+	}, nil, opts...)
+	if err != nil {
+		panic(err) // the wrapped function's signature is fixed
+	}
+	return h
+}
+
+// written is the response of upgraded requests.
+var written = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+// closeReason returns the message clients get for err,
+// see [jh.ErrorOf], cut to fit in a close frame.
+func closeReason(err error) string {
+	msg := jh.ErrorOf(err).Message
+	// control frames carry at most 125 bytes, 2 of which are the code
+	if len(msg) > 123 {
+		msg = strings.ToValidUTF8(msg[:123], "")
+	}
+	return msg
+}
+
+// hijacker lets the upgrader hijack writers that only
+// expose Hijack through [http.ResponseController],
+// like middleware wrappers with an Unwrap method.
+type hijacker struct {
+	http.ResponseWriter
+}
+
+func (w hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
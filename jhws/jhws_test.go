@@ -0,0 +1,92 @@
+package jhws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ryandotsmith/jh"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler(nil, func(ctx context.Context, conn *websocket.Conn) error {
+		if jh.Request(ctx).URL.Path != "/echo" {
+			return errors.New("missing request")
+		}
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if string(msg) == "fail" {
+				return errors.New("asked to fail")
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return err
+			}
+		}
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/echo"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "hi" {
+		t.Fatalf("got %q %v", msg, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("fail"))
+	_, _, err = conn.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != websocket.CloseInternalServerErr || ce.Text != "asked to fail" {
+		t.Errorf("got %v want a close error", err)
+	}
+}
+
+func TestHandlerNotUpgrade(t *testing.T) {
+	var got error
+	h := Handler(nil, func(ctx context.Context, conn *websocket.Conn) error {
+		t.Error("f called for a request that isn't an upgrade")
+		return nil
+	}, jh.ErrFunc(func(ctx context.Context, w http.ResponseWriter, err error) {
+		got = err
+		jh.ErrHandler(ctx, w, err)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/echo", nil))
+	if rec.Code != 400 {
+		t.Errorf("got %d want 400", rec.Code)
+	}
+	var jhe jh.Error
+	if !errors.As(got, &jhe) || jhe.Code != 400 {
+		t.Errorf("got %v want a 400 jh.Error", got)
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	defer func(msg string) { jh.DefaultErrorMessage = msg }(jh.DefaultErrorMessage)
+	jh.DefaultErrorMessage = "internal error"
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("dial tcp 10.0.0.1:5432: connection refused"), "internal error"},
+		{jh.BadRequest("bad frame"), "bad frame"},
+		{jh.BadRequest(strings.Repeat("x", 200)), strings.Repeat("x", 123)},
+	}
+	for _, c := range cases {
+		if got := closeReason(c.err); got != c.want {
+			t.Errorf("%v: got %q want %q", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package jh
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// A LogFunc is called after a handler serves a request.
+// status is the status that was written, dur is how long
+// the handler took and err is the error that was passed to
+// the error func, if any. When writing a successful response
+// fails, eg because the client went away, err is the write
+// error. errors.Is(err, context.Canceled) reports whether
+// the client disconnected before the response was written.
+// [Written] reports the size of the response.
+type LogFunc func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error)
+
+var logger atomic.Pointer[LogFunc]
+
+// SetLogger sets the function that every handler calls after
+// serving a request. It's called on both the success and error
+// paths. A nil f turns logging off, which is the default.
+func SetLogger(f LogFunc) {
+	if f == nil {
+		logger.Store(nil)
+		return
+	}
+	logger.Store(&f)
+}
+
+// Slog returns a LogFunc that logs requests to l with the
+// attributes method, path, status, bytes and duration, plus
+// request_id when [RequestIDHandler] assigned one and operation
+// when the handler has an [Operation] ID. Requests served without an
+// error are logged at slog.LevelInfo. The others are logged at
+// errLevel, eg slog.LevelWarn or slog.LevelError, with an error
+// attribute.
+//
+//	jh.SetLogger(jh.Slog(slog.Default(), slog.LevelError))
+func Slog(l *slog.Logger, errLevel slog.Level) LogFunc {
+	return func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error) {
+		level := slog.LevelInfo
+		if err != nil {
+			level = errLevel
+		}
+		if !l.Enabled(ctx, level) {
+			return
+		}
+		_, bytes := Written(ctx)
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", bytes),
+			slog.Duration("duration", dur),
+		}
+		if id := RequestID(ctx); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		if op := Operation(ctx); op != "" {
+			attrs = append(attrs, slog.String("operation", op))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		l.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
@@ -0,0 +1,144 @@
+package jh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetLogger(t *testing.T) {
+	type entry struct {
+		method, path string
+		status       int
+		err          error
+	}
+	var got []entry
+	SetLogger(func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error) {
+		if Request(ctx) != r {
+			t.Error("ctx is missing the request")
+		}
+		got = append(got, entry{r.Method, r.URL.Path, status, err})
+	})
+	defer SetLogger(nil)
+
+	h, _ := Handler(echoPoint, ErrHandler)
+	create := HandlerFunc(func(ctx context.Context, p point) (*point, error) {
+		WithStatus(ctx, 201)
+		return &p, nil
+	})
+
+	recs := []*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+	h.ServeHTTP(recs[0], httptest.NewRequest("POST", "/a", strings.NewReader(`{}`)))
+	h.ServeHTTP(recs[1], httptest.NewRequest("POST", "/b", strings.NewReader(`{`)))
+	create.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/c", strings.NewReader(`{}`)))
+
+	if len(got) != 3 {
+		t.Fatalf("got %d entries want 3", len(got))
+	}
+	if e := got[0]; e.method != "POST" || e.path != "/a" || e.status != 200 || e.err != nil {
+		t.Errorf("got %+v", e)
+	}
+	if e := got[1]; e.path != "/b" || e.status != 400 || e.err == nil {
+		t.Errorf("got %+v", e)
+	}
+	if e := got[2]; e.method != "PUT" || e.status != 201 {
+		t.Errorf("got %+v", e)
+	}
+
+	SetLogger(nil)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/d", strings.NewReader(`{}`)))
+	if len(got) != 3 {
+		t.Errorf("got %d entries want 3", len(got))
+	}
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestLogWriteErrors(t *testing.T) {
+	var got error
+	SetLogger(func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error) {
+		got = err
+	})
+	defer SetLogger(nil)
+	h := HandlerFunc(echoPoint)
+
+	h.ServeHTTP(failingWriter{httptest.NewRecorder()}, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if got == nil || !strings.Contains(got.Error(), "broken pipe") {
+		t.Errorf("got %v want the write error", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := HandlerFunc(func(ctx context.Context, p point) (*point, error) {
+		cancel() // the client goes away while the response is prepared
+		return &p, nil
+	})
+	var (
+		r   = httptest.NewRequest("POST", "/", strings.NewReader(`{}`)).WithContext(ctx)
+		rec = httptest.NewRecorder()
+	)
+	slow.ServeHTTP(rec, r)
+	if !errors.Is(got, context.Canceled) {
+		t.Errorf("got %v want %v", got, context.Canceled)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("got = %q; want no body", rec.Body)
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogger(Slog(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelWarn))
+	defer SetLogger(nil)
+
+	h := RequestIDHandler(HandlerFunc(echoPoint))
+	recs := []*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+	h.ServeHTTP(recs[0], httptest.NewRequest("POST", "/a", strings.NewReader(`{}`)))
+	h.ServeHTTP(recs[1], httptest.NewRequest("POST", "/b", strings.NewReader(`{`)))
+
+	var got []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d records want 2", len(got))
+	}
+	want := []map[string]any{
+		{"level": "INFO", "msg": "request", "method": "POST", "path": "/a", "status": float64(200)},
+		{"level": "WARN", "msg": "request", "method": "POST", "path": "/b", "status": float64(400),
+			"error": "jh: malformed JSON: body ends unexpectedly"},
+	}
+	for i, w := range want {
+		for k, v := range w {
+			if got[i][k] != v {
+				t.Errorf("record %d: got %s %v want %v", i, k, got[i][k], v)
+			}
+		}
+		if got[i]["request_id"] == nil || got[i]["duration"] == nil {
+			t.Errorf("record %d: got %v", i, got[i])
+		}
+		if want := float64(recs[i].Body.Len()); got[i]["bytes"] != want {
+			t.Errorf("record %d: got bytes %v want %v", i, got[i]["bytes"], want)
+		}
+	}
+	if _, ok := got[0]["error"]; ok {
+		t.Errorf("got error attribute %v", got[0]["error"])
+	}
+}
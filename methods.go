@@ -0,0 +1,38 @@
+package jh
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Methods returns middleware that only lets requests with the
+// given methods through. Other requests get a 405 with an Allow
+// header listing methods. HEAD is allowed along with GET.
+//
+//	http.Handle("/users", Methods("POST", "PUT")(HandlerFunc(saveUser)))
+func Methods(methods ...string) func(http.Handler) http.Handler {
+	allowed := make([]string, 0, len(methods)+1)
+	for _, m := range methods {
+		allowed = append(allowed, strings.ToUpper(m))
+	}
+	if slices.Contains(allowed, http.MethodGet) && !slices.Contains(allowed, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	allow := strings.Join(allowed, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(allowed, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Allow", allow)
+			ErrHandler(r.Context(), w, Error{
+				Code:    http.StatusMethodNotAllowed,
+				Message: fmt.Sprintf("method %s not allowed", r.Method),
+			})
+		})
+	}
+}
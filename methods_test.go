@@ -0,0 +1,46 @@
+package jh
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	ok, _ := Handler(func(ctx context.Context) (*struct{}, error) {
+		return &struct{}{}, nil
+	}, ErrHandler)
+	h := Methods("post", "GET")(ok)
+
+	cases := []struct {
+		method    string
+		want      int
+		wantAllow string
+	}{
+		{"POST", 200, ""},
+		{"GET", 200, ""},
+		{"HEAD", 200, ""},
+		{"DELETE", 405, "POST, GET, HEAD"},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest(c.method, "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("%s: got %d want %d", c.method, rec.Code, c.want)
+		}
+		if got := rec.Header().Get("Allow"); got != c.wantAllow {
+			t.Errorf("%s: got Allow %q want %q", c.method, got, c.wantAllow)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PATCH", "/", nil))
+	want := `{"message":"method PATCH not allowed"}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
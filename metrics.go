@@ -0,0 +1,58 @@
+package jh
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// A Collector records metrics for requests served by
+// [Instrument] handlers. Implementations typically update a
+// request counter and a latency histogram labeled by name and
+// status. Since name is chosen when a handler is instrumented
+// rather than taken from the request's path, the number of
+// distinct labels stays bounded. Implementations that want
+// fewer labels can bucket status into classes like "2xx".
+type Collector interface {
+	Observe(name string, status int, dur time.Duration)
+}
+
+// The CollectorFunc type is an adapter to allow the use of
+// ordinary functions as Collectors.
+type CollectorFunc func(name string, status int, dur time.Duration)
+
+func (f CollectorFunc) Observe(name string, status int, dur time.Duration) {
+	f(name, status, dur)
+}
+
+var collector atomic.Pointer[Collector]
+
+// SetCollector sets the Collector used by [Instrument] handlers.
+// A nil c turns metrics off, which is the default.
+func SetCollector(c Collector) {
+	if c == nil {
+		collector.Store(nil)
+		return
+	}
+	collector.Store(&c)
+}
+
+// Instrument reports the latency and status of every request
+// served by h to the [Collector] using name as the label.
+// The status is the one that reached the client, including
+// the statuses of error responses.
+//
+//	http.Handle("/add", Instrument("add", HandlerFunc(add)))
+func Instrument(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := collector.Load()
+		if c == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+		(*c).Observe(name, rw.Status(), time.Since(start))
+	})
+}
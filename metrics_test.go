@@ -0,0 +1,30 @@
+package jh
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrument(t *testing.T) {
+	type obs struct {
+		name   string
+		status int
+	}
+	var got []obs
+	SetCollector(CollectorFunc(func(name string, status int, dur time.Duration) {
+		got = append(got, obs{name, status})
+	}))
+	defer SetCollector(nil)
+
+	h, _ := Handler(echoPoint, ErrHandler)
+	h = Instrument("echo", h)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/1", strings.NewReader(`{}`)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/2", strings.NewReader(`nope`)))
+
+	want := []obs{{"echo", 200}, {"echo", 400}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,345 @@
+package jh
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mux is a request router that registers wrapped functions
+// and remembers their types. Routing is done by an
+// [http.ServeMux] so patterns have the same syntax.
+//
+//	m := NewMux()
+//	m.Handle("POST /add", add)
+//	http.ListenAndServe(":8080", m)
+//
+// Requests that match no route get a 404 [Error] written by
+// [ErrHandler], so they're JSON like other errors, unless
+// [Mux.NotFound] sets another handler.
+//
+// OPTIONS requests to a path without an OPTIONS route get a 204
+// No Content with an Allow header listing the methods registered
+// for the path, eg "Allow: DELETE, GET, HEAD, OPTIONS". Requests
+// with other methods that aren't registered for a path get the
+// ServeMux's 405 Method Not Allowed with the same header.
+//
+// Middleware added with [Mux.Use] wraps the whole Mux and
+// middleware added with [Mux.With] wraps single routes. A request
+// goes through them in a fixed order:
+//
+//	Use middleware, in the order added
+//	routing by the ServeMux
+//	With middleware, in the order added
+//	the route's handler
+//
+// The route's handler decodes the request, calls the wrapped
+// function and writes its errors with the error func, so all of
+// the middleware sees errors as written responses. The handler
+// recovers panics in wrapped functions as well, which leaves
+// recovery middleware the panics of other middleware. It and
+// middleware measuring whole requests, like metrics, belong
+// first in Use.
+type Mux struct {
+	mux  *http.ServeMux
+	opts []Option
+
+	// with wraps the routes this Mux registers.
+	with []func(http.Handler) http.Handler
+
+	*muxState
+}
+
+// muxState is shared by a Mux and those returned by its With.
+type muxState struct {
+	mu     sync.RWMutex
+	routes []RouteInfo
+	use    []func(http.Handler) http.Handler
+	h      http.Handler // route wrapped by use
+
+	// methods holds the methods of the routes' patterns.
+	methods map[string]bool
+
+	notFound http.Handler // set by NotFound
+}
+
+// RouteInfo describes a route registered with a [Mux]
+// or, without a Pattern, a handler passed to [Info].
+type RouteInfo struct {
+	Pattern string
+
+	// Aliases are the other patterns of a route
+	// registered with [Mux.HandleMany].
+	Aliases []string
+
+	// Request and Response are the wrapped function's request
+	// and response types. Request is nil when the function only
+	// takes a context and Response is nil when it only
+	// returns an error. For [HandlerWithParams] handlers
+	// Request is the params type and for a [Response]
+	// Response is the Body type.
+	Request  reflect.Type
+	Response reflect.Type
+
+	// NumIn is the number of the wrapped function's
+	// parameters, 1 or 2 when it takes a request.
+	NumIn int
+
+	// OperationID is the name of the route's operation set with
+	// the [OperationID] option or derived from the Pattern.
+	OperationID string
+
+	// ExampleRequest and ExampleResponse are the
+	// payloads added with the [Example] option.
+	ExampleRequest  any
+	ExampleResponse any
+
+	h *handler
+}
+
+// NewMux returns a Mux that applies opts to every route.
+// Errors are handled by [ErrHandler] unless
+// the [ErrFunc] option is used.
+func NewMux(opts ...Option) *Mux {
+	return &Mux{
+		mux:      http.NewServeMux(),
+		opts:     opts,
+		muxState: &muxState{},
+	}
+}
+
+// Use adds middleware around the whole Mux, after any added
+// before. It applies to every request, including those that
+// match no route, and to routes registered with a Mux
+// returned by [Mux.With]. See [Mux] for the order.
+func (m *Mux) Use(mw ...func(http.Handler) http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.use = append(m.use, mw...)
+	m.h = Chain(http.HandlerFunc(m.route), m.use...)
+}
+
+// NotFound sets the handler for requests that match no route.
+// It's wrapped by the Use middleware like the routes are.
+func (m *Mux) NotFound(h http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notFound = h
+}
+
+// With returns a Mux that registers routes on m wrapped with mw,
+// after m's own With middleware. It shares m's routes, options
+// and Use middleware:
+//
+//	admin := m.With(jh.Auth(adminOnly))
+//	admin.Handle("DELETE /widgets/{id}", deleteWidget)
+func (m *Mux) With(mw ...func(http.Handler) http.Handler) *Mux {
+	with := append(append([]func(http.Handler) http.Handler{}, m.with...), mw...)
+	return &Mux{
+		mux:      m.mux,
+		opts:     m.opts,
+		with:     with,
+		muxState: m.muxState,
+	}
+}
+
+// Handle registers wrappedFunc for pattern.
+// wrappedFunc has one of the forms accepted by [Handler].
+// opts are applied after the Mux's options.
+// An error is returned when wrappedFunc has the wrong form
+// or when pattern is invalid or conflicts with another route.
+func (m *Mux) Handle(pattern string, wrappedFunc any, opts ...Option) error {
+	return m.HandleMany([]string{pattern}, wrappedFunc, opts...)
+}
+
+// HandleMany is like [Mux.Handle] but registers one handler
+// for several patterns, eg a versioned and a legacy path:
+//
+//	m.HandleMany([]string{"GET /v2/widgets", "GET /widgets"}, listWidgets)
+//
+// [Mux.Routes] lists it once, with the first pattern as its
+// Pattern and the others as its Aliases. Patterns are registered
+// in order, so on an error the ones before the pattern that failed
+// stay registered and the route lists only them.
+func (m *Mux) HandleMany(patterns []string, wrappedFunc any, opts ...Option) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("jh: mux: no patterns")
+	}
+	for _, pattern := range patterns {
+		if _, _, err := splitPattern(pattern); err != nil {
+			return err
+		}
+	}
+	all := append(append([]Option{}, m.opts...), opts...)
+	h, err := Handler(wrappedFunc, ErrHandler, all...)
+	if err != nil {
+		return err
+	}
+	jhh := h.(*handler)
+	info := jhh.info(patterns[0])
+	jhh.operationID = info.OperationID
+	wrapped := Chain(h, m.with...)
+	n := 0
+	for ; n < len(patterns); n++ {
+		if err = m.register(patterns[n], wrapped); err != nil {
+			break
+		}
+	}
+	if n == 0 {
+		return err
+	}
+	if n > 1 {
+		info.Aliases = append([]string(nil), patterns[1:n]...)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, info)
+	for _, pattern := range patterns[:n] {
+		method, _, _ := splitPattern(pattern)
+		if method == "" {
+			continue
+		}
+		if m.methods == nil {
+			m.methods = map[string]bool{}
+		}
+		m.methods[method] = true
+		if method == http.MethodGet {
+			m.methods[http.MethodHead] = true
+		}
+	}
+	return err
+}
+
+// register adds h to the ServeMux
+// converting its panics into errors.
+func (m *Mux) register(pattern string, h http.Handler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("jh: mux: %v", v)
+		}
+	}()
+	m.mux.Handle(pattern, h)
+	return nil
+}
+
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	h := m.h
+	m.mu.RUnlock()
+	if h == nil {
+		h = http.HandlerFunc(m.route)
+	}
+	h.ServeHTTP(w, r)
+}
+
+// route serves r with the ServeMux. OPTIONS requests for paths
+// without an OPTIONS route get a 204 with an Allow header
+// listing the methods of the routes matching the path.
+// Requests for paths no route matches go to the NotFound handler.
+func (m *Mux) route(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := m.mux.Handler(r); pattern == "" {
+		allow := m.allow(r)
+		switch {
+		case allow == "":
+			m.serveNotFound(w, r)
+			return
+		case r.Method == http.MethodOptions:
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	m.mux.ServeHTTP(w, r)
+}
+
+func (m *Mux) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	h := m.notFound
+	m.mu.RUnlock()
+	if h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	ErrHandler(r.Context(), w, NotFound("not found"))
+}
+
+// allow returns the value of the Allow header for a request r
+// that no route matches. It's empty when no route
+// matches r's path with any method.
+func (m *Mux) allow(r *http.Request) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var allowed []string
+	for method := range m.methods {
+		rm := *r
+		rm.Method = method
+		if _, pattern := m.mux.Handler(&rm); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		return ""
+	}
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// Routes returns the registered routes in the
+// order they were registered.
+func (m *Mux) Routes() []RouteInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]RouteInfo(nil), m.routes...)
+}
+
+// OpenAPI is like [OpenAPI] for the Mux's routes. A route's
+// Aliases are described as operations of their own, with
+// operation IDs derived from their patterns.
+func (m *Mux) OpenAPI() ([]byte, error) {
+	return openAPI(m.Routes())
+}
+
+// Info describes h, which must be returned by [Handler] or
+// [HandlerFunc]. It reports false for other handlers.
+// Tests and doc generators can use it to build example
+// payloads of the right types:
+//
+//	info, _ := jh.Info(h)
+//	req := reflect.New(info.Request).Interface()
+func Info(h http.Handler) (RouteInfo, bool) {
+	jhh, ok := h.(*handler)
+	if !ok {
+		return RouteInfo{}, false
+	}
+	return jhh.info(""), true
+}
+
+func (h *handler) info(pattern string) RouteInfo {
+	numIn := 1
+	if h.newReq != nil && !h.paramsOnly {
+		numIn = 2
+	}
+	id := h.operationID
+	if id == "" && pattern != "" {
+		if method, path, err := splitPattern(pattern); err == nil {
+			id = defaultOperationID(defaultMethod(method, numIn), path)
+		}
+	}
+	return RouteInfo{
+		Pattern:     pattern,
+		Request:     h.reqType,
+		Response:    h.respType,
+		NumIn:       numIn,
+		OperationID: id,
+
+		ExampleRequest:  h.exampleReq,
+		ExampleResponse: h.exampleResp,
+
+		h: h,
+	}
+}
@@ -0,0 +1,368 @@
+package jh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	var errFuncCalled bool
+	m := NewMux(ErrFunc(func(ctx context.Context, w http.ResponseWriter, err error) {
+		errFuncCalled = true
+		ErrHandler(ctx, w, err)
+	}))
+	if err := m.Handle("GET /widgets/{id}", func(ctx context.Context, r getWidget) (*widget, error) {
+		return &widget{ID: r.ID}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Handle("PUT /widgets/{id}", func(ctx context.Context, r putWidget) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Handle("POST /bad", func() {}); !errors.Is(err, ErrTooFewArgs) {
+		t.Errorf("got %v want %v", err, ErrTooFewArgs)
+	}
+	if err := m.Handle("GET /widgets/{id}", echoPoint); err == nil {
+		t.Error("expected conflict error")
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/widgets/7", nil))
+	if got := rec.Body.String(); !strings.HasPrefix(got, `{"id":7,`) {
+		t.Errorf("got = %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/widgets/x", nil))
+	if rec.Code != 400 || !errFuncCalled {
+		t.Errorf("got %d, errFuncCalled %t", rec.Code, errFuncCalled)
+	}
+
+	want := []RouteInfo{
+		{Pattern: "GET /widgets/{id}", Request: reflect.TypeOf(getWidget{}), Response: reflect.TypeOf(&widget{}), NumIn: 2},
+		{Pattern: "PUT /widgets/{id}", Request: reflect.TypeOf(putWidget{}), NumIn: 2},
+	}
+	got := m.Routes()
+	if len(got) != len(want) {
+		t.Fatalf("got %d routes want %d", len(got), len(want))
+	}
+	for i := range want {
+		g := got[i]
+		if g.Pattern != want[i].Pattern || g.Request != want[i].Request || g.Response != want[i].Response || g.NumIn != want[i].NumIn {
+			t.Errorf("route %d: got %+v want %+v", i, g, want[i])
+		}
+	}
+
+	b, err := m.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]any
+	}
+	json.Unmarshal(b, &doc)
+	if len(doc.Paths["/widgets/{id}"]) != 2 {
+		t.Errorf("got %v", doc.Paths)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	list, _ := Handler(func(ctx context.Context) ([]widget, error) {
+		return nil, nil
+	}, ErrHandler)
+	cases := []struct {
+		h    http.Handler
+		want RouteInfo
+		ok   bool
+	}{
+		{HandlerFunc(echoPoint), RouteInfo{Request: reflect.TypeOf(point{}), Response: reflect.TypeOf(&point{}), NumIn: 2}, true},
+		{list, RouteInfo{Response: reflect.TypeOf([]widget{}), NumIn: 1}, true},
+		{http.NotFoundHandler(), RouteInfo{}, false},
+	}
+	for i, c := range cases {
+		got, ok := Info(c.h)
+		if ok != c.ok || got.Request != c.want.Request || got.Response != c.want.Response || got.NumIn != c.want.NumIn {
+			t.Errorf("case %d: got %+v, %t want %+v, %t", i, got, ok, c.want, c.ok)
+		}
+	}
+
+	info, _ := Info(HandlerFunc(echoPoint))
+	req := reflect.New(info.Request).Interface()
+	if _, ok := req.(*point); !ok {
+		t.Errorf("got %T want *point", req)
+	}
+}
+
+func TestMuxMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	m := NewMux()
+	m.Use(trace("use1"))
+	m.Use(trace("use2"))
+	admin := m.With(trace("with1")).With(trace("with2"))
+	if err := admin.Handle("DELETE /widgets/{id}", func(ctx context.Context, r getWidget) error {
+		order = append(order, "handler")
+		return NotFound("no widget")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Handle("GET /widgets/{id}", func(ctx context.Context, r getWidget) error {
+		order = append(order, "handler")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, target string
+		wantStatus     int
+		wantOrder      string
+	}{
+		{"DELETE", "/widgets/1", 404, "use1, use2, with1, with2, handler"},
+		{"GET", "/widgets/1", 204, "use1, use2, handler"},
+		{"GET", "/missing", 404, "use1, use2"},
+	}
+	for _, c := range cases {
+		order = nil
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, strings.NewReader("{}")))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s %s: got %d want %d", c.method, c.target, rec.Code, c.wantStatus)
+		}
+		if got := strings.Join(order, ", "); got != c.wantOrder {
+			t.Errorf("%s %s: got %q want %q", c.method, c.target, got, c.wantOrder)
+		}
+	}
+	if got := len(m.Routes()); got != 2 {
+		t.Errorf("got %d routes want 2", got)
+	}
+}
+
+func TestMuxOptions(t *testing.T) {
+	m := NewMux()
+	for _, pattern := range []string{"GET /widgets/{id}", "DELETE /widgets/{id}", "POST /widgets", "/any", "OPTIONS /custom", "PUT /custom"} {
+		if err := m.Handle(pattern, func(ctx context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		method, target string
+		wantStatus     int
+		wantAllow      string
+	}{
+		{"OPTIONS", "/widgets/7", 204, "DELETE, GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/widgets", 204, "OPTIONS, POST"},
+		{"PATCH", "/widgets/7", 405, "DELETE, GET, HEAD"},
+		{"OPTIONS", "/any", 204, ""},
+		{"OPTIONS", "/custom", 204, ""},
+		{"OPTIONS", "/missing", 404, ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, nil))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s %s: got %d want %d", c.method, c.target, rec.Code, c.wantStatus)
+		}
+		if got := rec.Header().Get("Allow"); got != c.wantAllow {
+			t.Errorf("%s %s: got Allow %q want %q", c.method, c.target, got, c.wantAllow)
+		}
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	var got []string
+	op := func(ctx context.Context) error {
+		got = append(got, Operation(ctx))
+		return nil
+	}
+	m := NewMux()
+	routes := []struct {
+		pattern string
+		opts    []Option
+		want    string
+	}{
+		{"GET /widgets/{id}", nil, "getWidgetsId"},
+		{"DELETE /widgets/{id}", []Option{OperationID("removeWidget")}, "removeWidget"},
+		{"/orgs/{org}/members/{path...}", nil, "getOrgsOrgMembersPath"},
+		{"GET /{$}", nil, "get"},
+	}
+	for _, r := range routes {
+		if err := m.Handle(r.pattern, op, r.opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, info := range m.Routes() {
+		if info.OperationID != routes[i].want {
+			t.Errorf("%s: got %q want %q", info.Pattern, info.OperationID, routes[i].want)
+		}
+	}
+
+	for _, target := range []string{"GET /widgets/1", "DELETE /widgets/1", "PUT /orgs/o/members/a/b", "GET /"} {
+		method, path, _ := strings.Cut(target, " ")
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, strings.NewReader("{}")))
+	}
+	want := []string{"getWidgetsId", "removeWidget", "getOrgsOrgMembersPath", "get"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got := Operation(context.Background()); got != "" {
+		t.Errorf("got %q want empty", got)
+	}
+
+	b, err := m.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		}
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Paths["/widgets/{id}"]["delete"].OperationID; got != "removeWidget" {
+		t.Errorf("got operationId %q want removeWidget", got)
+	}
+}
+
+func TestHandleMany(t *testing.T) {
+	m := NewMux()
+	patterns := []string{"GET /v2/widgets/{id}", "GET /widgets/{id}", "GET /legacy/widget/{id}"}
+	err := m.HandleMany(patterns, func(ctx context.Context, r struct {
+		ID string `path:"id"`
+	}) (*string, error) {
+		return &r.ID, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/v2/widgets/7", "/widgets/7", "/legacy/widget/7"} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != 200 || got != `"7"` {
+			t.Errorf("%s: got %d %s", path, rec.Code, got)
+		}
+	}
+
+	routes := m.Routes()
+	if len(routes) != 1 {
+		t.Fatalf("got %d routes want 1", len(routes))
+	}
+	if got := routes[0]; got.Pattern != patterns[0] || !reflect.DeepEqual(got.Aliases, patterns[1:]) {
+		t.Errorf("got %q %q", got.Pattern, got.Aliases)
+	}
+
+	b, err := m.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		}
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/v2/widgets/{id}":    "getV2WidgetsId",
+		"/widgets/{id}":       "getWidgetsId",
+		"/legacy/widget/{id}": "getLegacyWidgetId",
+	} {
+		if got := doc.Paths[path]["get"].OperationID; got != want {
+			t.Errorf("%s: got operationId %q want %q", path, got, want)
+		}
+	}
+
+	if err := m.HandleMany(nil, func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("expected error without patterns")
+	}
+	if err := m.HandleMany([]string{"PUT /a", "GET /widgets/{id}", "GET /b"}, func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("expected error for a conflicting alias")
+	}
+	// the patterns registered before the conflict are routes
+	routes = m.Routes()
+	if len(routes) != 2 || routes[1].Pattern != "PUT /a" || routes[1].Aliases != nil {
+		t.Errorf("got %+v", routes)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("DELETE", "/a", nil))
+	if rec.Code != 405 || rec.Header().Get("Allow") == "" {
+		t.Errorf("DELETE /a: got %d Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+	if b, _ := m.OpenAPI(); !strings.Contains(string(b), `"/a"`) {
+		t.Error("OpenAPI doesn't describe /a")
+	}
+	if err := m.HandleMany([]string{"GET /c", "nowhere"}, func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+	if len(m.Routes()) != 2 {
+		t.Error("invalid patterns registered a route")
+	}
+}
+
+func TestMuxNotFound(t *testing.T) {
+	m := NewMux()
+	var used []string
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			used = append(used, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	})
+	if err := m.Handle("GET /widgets", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, path string
+		wantStatus   int
+		wantType     string
+		wantBody     string
+	}{
+		{"GET", "/missing", 404, "application/json; charset=utf-8", `{"message":"not found"}`},
+		{"POST", "/widgets", 405, "", ""},
+		{"GET", "/widgets", 204, "", ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s %s: got %d want %d", c.method, c.path, rec.Code, c.wantStatus)
+		}
+		if got := rec.Header().Get("Content-Type"); c.wantType != "" && got != c.wantType {
+			t.Errorf("%s %s: got Content-Type %q want %q", c.method, c.path, got, c.wantType)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); c.wantBody != "" && got != c.wantBody {
+			t.Errorf("%s %s: got = %s; want %s", c.method, c.path, got, c.wantBody)
+		}
+	}
+
+	m.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ErrHandler(r.Context(), w, Error{Code: 404, Message: "no route for " + r.URL.Path, Kind: "no_route"})
+	}))
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/gone", nil))
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != 404 || got != `{"message":"no route for /gone","kind":"no_route"}` {
+		t.Errorf("got %d %s", rec.Code, got)
+	}
+	if want := []string{"/missing", "/widgets", "/widgets", "/gone"}; !reflect.DeepEqual(used, want) {
+		t.Errorf("got %q want %q", used, want)
+	}
+}
@@ -0,0 +1,466 @@
+package jh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenAPI returns an OpenAPI 3 document describing handlers.
+// The keys of handlers are [http.ServeMux] patterns like
+// "POST /users/{id}" and the values must be returned by
+// [Handler] or [HandlerFunc]. Patterns without a method are
+// described as POST when the wrapped function takes a
+// request and GET otherwise.
+//
+// Request and response types are described using JSON Schema.
+// Property names follow the `json` struct tag and the `doc` tag
+// sets a field's description. Fields tagged with path, query,
+// header or cookie become parameters. Named struct types are
+// components named after the type, qualified by its package's
+// name when another type has the same one. Payloads added with
+// the [Example] option are included as examples.
+//
+// The document's info object is a placeholder
+// for callers to replace.
+func OpenAPI(handlers map[string]http.Handler) ([]byte, error) {
+	patterns := make([]string, 0, len(handlers))
+	for pattern := range handlers {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	routes := make([]RouteInfo, len(patterns))
+	for i, pattern := range patterns {
+		h, ok := handlers[pattern].(*handler)
+		if !ok {
+			return nil, fmt.Errorf("jh: openapi: %q: %T isn't a jh handler", pattern, handlers[pattern])
+		}
+		routes[i] = h.info(pattern)
+	}
+	return openAPI(routes)
+}
+
+func openAPI(routes []RouteInfo) ([]byte, error) {
+	var (
+		paths = map[string]map[string]any{}
+		comps = newComponents()
+	)
+	for _, route := range routes {
+		for i, pattern := range append([]string{route.Pattern}, route.Aliases...) {
+			method, path, err := splitPattern(pattern)
+			if err != nil {
+				return nil, err
+			}
+			method = defaultMethod(method, route.NumIn)
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+			op, err := route.h.operation(method, comps)
+			if err != nil {
+				return nil, fmt.Errorf("jh: openapi: %q: %w", pattern, err)
+			}
+			// operation IDs are unique, aliases get derived ones
+			id := route.OperationID
+			if i > 0 {
+				id = defaultOperationID(method, path)
+			}
+			if id != "" {
+				op["operationId"] = id
+			}
+			paths[path][strings.ToLower(method)] = op
+		}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "API",
+			"version": "0.0.0",
+		},
+		"paths": paths,
+	}
+	if len(comps.schemas) > 0 {
+		doc["components"] = map[string]any{"schemas": comps.schemas}
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// splitPattern returns the method and the OpenAPI path
+// template of a ServeMux pattern.
+func splitPattern(pattern string) (method, path string, err error) {
+	method, rest, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, rest = "", pattern
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return "", "", fmt.Errorf("jh: openapi: invalid pattern %q", pattern)
+	}
+	path = rest[i:] // drop the host
+	path = strings.ReplaceAll(path, "{$}", "")
+	path = strings.ReplaceAll(path, "...}", "}")
+	return method, path, nil
+}
+
+// defaultMethod returns the method that describes a pattern
+// with method: a wrapped function with numIn parameters that
+// takes a request is a POST for patterns without a method
+// and a GET otherwise.
+func defaultMethod(method string, numIn int) string {
+	switch {
+	case method != "":
+		return method
+	case numIn == 2:
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// defaultOperationID derives an operation ID from a method and
+// an OpenAPI path, eg "getWidgetsId" from GET /widgets/{id}.
+func defaultOperationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+type errorSchema struct {
+	Message string `json:"message"`
+	Kind    string `json:"kind,omitempty"`
+}
+
+func (h *handler) operation(method string, comps *components) (map[string]any, error) {
+	op := map[string]any{}
+
+	if h.reqType != nil {
+		params := parameters(h.reqType)
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if method != http.MethodGet && method != http.MethodHead && !h.paramsOnly {
+			s, err := schemaOf(h.reqType, comps, true)
+			if err != nil {
+				return nil, err
+			}
+			media := map[string]any{"schema": s}
+			if h.exampleReq != nil {
+				media["example"] = h.exampleReq
+			}
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": media,
+				},
+			}
+		}
+	}
+
+	responses := map[string]any{}
+	switch {
+	case h.noContent:
+		responses["204"] = map[string]any{"description": "No Content"}
+	case h.respType != nil && (h.respType.Implements(readerType) || h.respType.Implements(writerToType)):
+		responses["200"] = map[string]any{
+			"description": "OK",
+			"content": map[string]any{
+				"application/octet-stream": map[string]any{
+					"schema": map[string]any{"type": "string", "format": "binary"},
+				},
+			},
+		}
+	case h.respType != nil:
+		s, err := schemaOf(h.respType, comps, false)
+		if err != nil {
+			return nil, err
+		}
+		media := map[string]any{"schema": s}
+		if h.exampleResp != nil {
+			media["example"] = h.exampleResp
+		}
+		responses["200"] = map[string]any{
+			"description": "OK",
+			"content": map[string]any{
+				"application/json": media,
+			},
+		}
+	}
+	es, _ := schemaOf(reflect.TypeOf(errorSchema{}), comps, false)
+	responses["default"] = map[string]any{
+		"description": "Error",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": es},
+		},
+	}
+	op["responses"] = responses
+	return op, nil
+}
+
+var (
+	readerType   = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	writerToType = reflect.TypeOf((*io.WriterTo)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// paramSources maps the tags that bind request
+// fields to OpenAPI parameter locations.
+var paramSources = []struct {
+	tag, in string
+}{
+	{"path", "path"},
+	{"query", "query"},
+	{"header", "header"},
+	{"cookie", "cookie"},
+}
+
+// parameters describes the fields of t that are
+// bound from outside of the request body.
+func parameters(t reflect.Type) []any {
+	var params []any
+	for _, src := range paramSources {
+		for _, sf := range structFields(t) {
+			name, ok := sf.Tag.Lookup(src.tag)
+			if !ok || name == "-" {
+				continue
+			}
+			s, _ := schemaOf(sf.Type, nil, false)
+			p := map[string]any{
+				"name":   name,
+				"in":     src.in,
+				"schema": s,
+			}
+			if src.in == "path" || isRequired(sf) {
+				p["required"] = true
+			}
+			if doc := sf.Tag.Get("doc"); doc != "" {
+				p["description"] = doc
+			}
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// components collects the named schemas of a document.
+// keys records what each name describes, so types of
+// the same name get their own.
+type components struct {
+	schemas map[string]any
+	keys    map[string]componentKey
+}
+
+type componentKey struct {
+	t    reflect.Type
+	body bool
+}
+
+func newComponents() *components {
+	return &components{
+		schemas: map[string]any{},
+		keys:    map[string]componentKey{},
+	}
+}
+
+// name returns the name of t's schema. It's t's name unless
+// another type has it, in which case it's qualified by the name of
+// t's package and then numbered. Body schemas get a "Body" suffix.
+func (c *components) name(t reflect.Type, body bool) string {
+	var (
+		key    = componentKey{t, body}
+		base   = componentName(t.Name())
+		suffix string
+	)
+	if body {
+		suffix = "Body"
+	}
+	pkg := t.PkgPath()
+	pkg = componentName(pkg[strings.LastIndex(pkg, "/")+1:])
+	for i := 0; ; i++ {
+		name := base + suffix
+		switch {
+		case i == 1 && pkg != "":
+			name = pkg + "." + base + suffix
+		case i > 0:
+			name = fmt.Sprintf("%s%s%d", base, suffix, i+1)
+		}
+		if k, ok := c.keys[name]; !ok || k == key {
+			return name
+		}
+	}
+}
+
+var (
+	pkgPathPrefix = regexp.MustCompile(`[^\[\],*\s]*/`)
+	nonNameChars  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// componentName turns a type name into a valid component
+// name, eg "Page_users.Item" from "Page[example.com/users.Item]".
+func componentName(name string) string {
+	name = pkgPathPrefix.ReplaceAllString(name, "")
+	name = nonNameChars.ReplaceAllString(name, "_")
+	return strings.Trim(name, "_")
+}
+
+// schemaOf returns the JSON Schema of t. Named struct types are
+// added to comps and referenced. A nil comps inlines them.
+// For request bodies, fields bound from parameters are omitted.
+func schemaOf(t reflect.Type, comps *components, body bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]any{"type": "string", "format": "byte"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}, nil
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}, nil
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(t.Elem(), comps, false)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		vals, err := schemaOf(t.Elem(), comps, false)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": vals}, nil
+	case reflect.Struct:
+		if t.Name() == "" || comps == nil {
+			return structSchema(t, comps, body)
+		}
+		name := comps.name(t, body)
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := comps.keys[name]; ok {
+			return ref, nil
+		}
+		// break cycles
+		comps.keys[name] = componentKey{t, body}
+		comps.schemas[name] = nil
+		s, err := structSchema(t, comps, body)
+		if err != nil {
+			delete(comps.keys, name)
+			delete(comps.schemas, name)
+			return nil, err
+		}
+		comps.schemas[name] = s
+		return ref, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func structSchema(t reflect.Type, comps *components, body bool) (map[string]any, error) {
+	var (
+		props    = map[string]any{}
+		required []string
+	)
+	if err := addProperties(t, comps, body, props, &required); err != nil {
+		return nil, err
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// addProperties adds the schemas of t's fields to props. Like
+// encoding/json does, the fields of embedded structs without
+// a JSON name are added as if they were t's unless t has
+// a field with the same name.
+func addProperties(t reflect.Type, comps *components, body bool, props map[string]any, required *[]string) error {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if body && !hasTag && isParam(sf) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if _, ok := props[name]; ok {
+			continue
+		}
+		s, err := schemaOf(sf.Type, comps, false)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		if doc := sf.Tag.Get("doc"); doc != "" {
+			if _, ok := s["$ref"]; ok {
+				s = map[string]any{"allOf": []any{s}}
+			}
+			s["description"] = doc
+		}
+		props[name] = s
+		if isRequired(sf) && !isParam(sf) {
+			*required = append(*required, name)
+		}
+	}
+	for _, et := range embedded {
+		if err := addProperties(et, comps, body, props, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isParam(sf reflect.StructField) bool {
+	for _, src := range paramSources {
+		if _, ok := sf.Tag.Lookup(src.tag); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package jh
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	ID      int       `json:"id" doc:"Unique ID"`
+	Name    string    `json:"name"`
+	Tags    []string  `json:"tags,omitempty"`
+	Created time.Time `json:"created"`
+	secret  string
+}
+
+type getWidget struct {
+	ID      int  `path:"id" doc:"Widget ID"`
+	Verbose bool `query:"verbose"`
+}
+
+type putWidget struct {
+	ID   int    `path:"id"`
+	Name string `json:"name" jh:"required"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	get, _ := Handler(func(ctx context.Context, r getWidget) (*widget, error) {
+		return nil, nil
+	}, ErrHandler)
+	put := HandlerFunc(func(ctx context.Context, r putWidget) (*widget, error) {
+		return nil, nil
+	}, Example(putWidget{Name: "gear"}, widget{ID: 1, Name: "gear"}))
+	del, _ := Handler(func(ctx context.Context, r getWidget) error {
+		return nil
+	}, ErrHandler)
+	list, _ := Handler(func(ctx context.Context) ([]widget, error) {
+		return nil, nil
+	}, ErrHandler)
+	export, _ := Handler(func(ctx context.Context) (io.Reader, error) {
+		return nil, nil
+	}, ErrHandler)
+
+	b, err := OpenAPI(map[string]http.Handler{
+		"GET /widgets/{id}":    get,
+		"PUT /widgets/{id}":    put,
+		"DELETE /widgets/{id}": del,
+		"/widgets":             list,
+		"GET /export/{$}":      export,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(path ...string) any {
+		var v any = doc
+		for _, p := range path {
+			m, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("%v: not an object at %q", path, p)
+			}
+			v = m[p]
+		}
+		return v
+	}
+	check := func(want any, path ...string) {
+		t.Helper()
+		if got := at(path...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v want %v", path, got, want)
+		}
+	}
+
+	check("3.0.3", "openapi")
+	check([]any{
+		map[string]any{"name": "id", "in": "path", "required": true, "description": "Widget ID",
+			"schema": map[string]any{"type": "integer", "format": "int32"}},
+		map[string]any{"name": "verbose", "in": "query",
+			"schema": map[string]any{"type": "boolean"}},
+	}, "paths", "/widgets/{id}", "get", "parameters")
+	check(nil, "paths", "/widgets/{id}", "get", "requestBody")
+	check("#/components/schemas/widget",
+		"paths", "/widgets/{id}", "get", "responses", "200", "content", "application/json", "schema", "$ref")
+	check("#/components/schemas/putWidgetBody",
+		"paths", "/widgets/{id}", "put", "requestBody", "content", "application/json", "schema", "$ref")
+	check(map[string]any{"name": map[string]any{"type": "string"}},
+		"components", "schemas", "putWidgetBody", "properties")
+	check([]any{"name"}, "components", "schemas", "putWidgetBody", "required")
+	check("gear", "paths", "/widgets/{id}", "put", "requestBody", "content", "application/json", "example", "name")
+	check(float64(1), "paths", "/widgets/{id}", "put", "responses", "200", "content", "application/json", "example", "id")
+	check(nil, "paths", "/widgets/{id}", "get", "responses", "200", "content", "application/json", "example")
+	check("No Content", "paths", "/widgets/{id}", "delete", "responses", "204", "description")
+	check("array", "paths", "/widgets", "get", "responses", "200", "content", "application/json", "schema", "type")
+	check("binary", "paths", "/export/", "get", "responses", "200", "content", "application/octet-stream", "schema", "format")
+	check(map[string]any{
+		"id":      map[string]any{"type": "integer", "format": "int32", "description": "Unique ID"},
+		"name":    map[string]any{"type": "string"},
+		"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"created": map[string]any{"type": "string", "format": "date-time"},
+	}, "components", "schemas", "widget", "properties")
+
+	if _, err := OpenAPI(map[string]http.Handler{"/": http.NotFoundHandler()}); err == nil {
+		t.Error("expected error for non jh handler")
+	}
+}
+
+func TestExample(t *testing.T) {
+	get := func(ctx context.Context, r getWidget) (*widget, error) {
+		return nil, nil
+	}
+	cases := []struct {
+		f       any
+		example Option
+		ok      bool
+	}{
+		{get, Example(getWidget{ID: 1}, &widget{ID: 1}), true},
+		{get, Example(nil, widget{ID: 1}), true},
+		{get, Example(putWidget{}, nil), false},
+		{get, Example(nil, "widget"), false},
+		{func(ctx context.Context) error { return nil }, Example(getWidget{}, nil), false},
+		{func(ctx context.Context) error { return nil }, Example(nil, widget{}), false},
+	}
+	for i, c := range cases {
+		_, err := Handler(c.f, ErrHandler, c.example)
+		if (err == nil) != c.ok {
+			t.Errorf("case %d: got %v", i, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("HandlerFunc didn't panic")
+		}
+	}()
+	HandlerFunc(get, Example(putWidget{}, nil))
+}
+
+func TestSchemaEmbedded(t *testing.T) {
+	type named struct {
+		Page `json:"page"`
+		Name string `json:"owner"`
+		*Owner
+	}
+	cases := []struct {
+		v    any
+		want []string
+	}{
+		{listRepos{}, []string{"after", "limit", "owner", "sort", "tenant"}},
+		{named{}, []string{"owner", "page"}},
+	}
+	for _, c := range cases {
+		s, err := schemaOf(reflect.TypeOf(c.v), nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for name := range s["properties"].(map[string]any) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%T: got %q want %q", c.v, got, c.want)
+		}
+	}
+}
+
+type page[T any] struct {
+	Items []T `json:"items"`
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+	users, _ := Handler(func(ctx context.Context) (*item, error) {
+		return nil, nil
+	}, ErrHandler)
+	orders := func() http.Handler {
+		type item struct {
+			Total float64 `json:"total"`
+		}
+		h, _ := Handler(func(ctx context.Context) (*item, error) {
+			return nil, nil
+		}, ErrHandler)
+		return h
+	}()
+	pages := HandlerFunc(func(ctx context.Context, _ struct{}) (*page[widget], error) {
+		return nil, nil
+	})
+
+	b, err := OpenAPI(map[string]http.Handler{
+		"GET /users/1":  users,
+		"GET /orders/1": orders,
+		"GET /widgets":  pages,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"errorSchema", "item", "jh.item", "page_jh.widget", "widget"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got %q want %q", names, want)
+	}
+	// patterns are described in order, so /orders/1 comes first
+	if _, ok := doc.Components.Schemas["item"].Properties["total"]; !ok {
+		t.Errorf("item: got %v", doc.Components.Schemas["item"])
+	}
+	if _, ok := doc.Components.Schemas["jh.item"].Properties["id"]; !ok {
+		t.Errorf("jh.item: got %v", doc.Components.Schemas["jh.item"])
+	}
+}
@@ -0,0 +1,352 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// An Option configures the handlers returned by
+// [Handler] and [HandlerFunc].
+type Option func(*handler)
+
+// ErrFunc sets the function that handles errors. It replaces
+// the errFunc passed to [Handler] and [ErrHandler], which is
+// used by [HandlerFunc] and [Mux] by default.
+// A nil f means [ErrHandler].
+func ErrFunc(f func(context.Context, http.ResponseWriter, error)) Option {
+	return func(h *handler) {
+		h.ef = f
+	}
+}
+
+// Codec makes the handler use enc and dec for contentType
+// instead of the codec registered with [RegisterCodec].
+// It's useful for formats only some handlers speak, eg
+// MessagePack between internal services. Using it with
+// application/json swaps encoding/json for another JSON
+// implementation, in which case the [DisallowUnknownFields],
+// [EscapeHTML] and [Indent] options have no effect.
+func Codec(contentType string, enc Encoder, dec Decoder) Option {
+	return func(h *handler) {
+		if h.codecs == nil {
+			h.codecs = map[string]codec{}
+		}
+		h.codecs[mediaType(contentType)] = codec{
+			contentType: contentType,
+			enc:         enc,
+			dec:         dec,
+		}
+	}
+}
+
+// DefaultMaxBodyBytes is the request body limit for handlers
+// that don't use the [MaxBodyBytes] option.
+// Zero means no limit.
+var DefaultMaxBodyBytes int64
+
+// MaxBodyBytes limits request bodies to n bytes.
+// The error func receives an [Error] with a 413 Code when
+// a body is too large. n <= 0 removes the limit, which
+// lets large upload endpoints opt out of [DefaultMaxBodyBytes].
+func MaxBodyBytes(n int64) Option {
+	return func(h *handler) {
+		h.maxBodyBytes = n
+	}
+}
+
+// MaxTeeBytes is the number of bytes of a request body kept by
+// the [TeeBody] option for handlers without a [MaxBodyBytes] limit.
+var MaxTeeBytes int64 = 1 << 20
+
+// TeeBody keeps the request body as the handler reads it so it can
+// be retrieved with [RawBody]. Since the body is held in memory
+// until the request is served, at most the handler's [MaxBodyBytes]
+// are kept, or [MaxTeeBytes] when there is no limit; the rest of
+// a longer body is still decoded but isn't kept.
+func TeeBody(tee bool) Option {
+	return func(h *handler) {
+		h.teeBody = tee
+	}
+}
+
+// ReuseBuffers makes the handler keep the buffers it reads request
+// bodies into for later requests instead of allocating new ones, which
+// eases GC pressure for services handling many medium-sized payloads.
+// Bodies are only buffered when they're checked before decoding, for
+// [WithSchema] or `jh:"required"` fields, and decoded with encoding/json.
+// Buffers that have grown past maxSize bytes are dropped, so a few large
+// bodies don't pin memory. Reused buffers are zeroed first. maxSize <= 0,
+// the default, disables reuse.
+func ReuseBuffers(maxSize int) Option {
+	return func(h *handler) {
+		h.maxBufferSize = maxSize
+	}
+}
+
+// DefaultDisallowUnknownFields is used by handlers
+// that don't use the [DisallowUnknownFields] option.
+var DefaultDisallowUnknownFields bool
+
+// DisallowUnknownFields makes JSON request bodies with keys
+// that don't match a field of the request type an error.
+// The error func receives an [Error] with a 400 Code that
+// names the unknown field.
+func DisallowUnknownFields(disallow bool) Option {
+	return func(h *handler) {
+		h.json.disallowUnknownFields = disallow
+	}
+}
+
+// ValidationStatus sets the status of errors for requests that
+// are well-formed but fail validation, eg 422 Unprocessable Entity.
+// These are errors returned by a [Validator] that aren't an [Error],
+// missing `jh:"required"` fields, [WithSchema] mismatches, values
+// of the wrong type and unknown fields. Malformed bodies and
+// other errors keep their status. The default is 400.
+func ValidationStatus(code int) Option {
+	return func(h *handler) {
+		h.validationStatus = code
+	}
+}
+
+// DisallowTrailingData makes JSON request bodies with anything
+// but whitespace after the first value, eg {"x":1}{"y":2}, an
+// error. The error func receives an [Error] with a 400 Code.
+// By default the rest of the body is ignored.
+func DisallowTrailingData(disallow bool) Option {
+	return func(h *handler) {
+		h.json.disallowTrailingData = disallow
+	}
+}
+
+// RequireContentType makes request bodies without a supported
+// Content-Type an error. Supported types are application/json,
+// application/x-www-form-urlencoded, multipart/form-data and
+// those added with [RegisterCodec]; parameters like charset are
+// ignored. The error func receives an [Error] with a 415 Code.
+// GET and HEAD requests without a body are exempt. By default
+// bodies of any other type are decoded as JSON.
+func RequireContentType(require bool) Option {
+	return func(h *handler) {
+		h.requireContentType = require
+	}
+}
+
+// RequireContentLength makes POST, PUT and PATCH requests without
+// a Content-Length header, like those with a chunked body, an error.
+// The error func receives an [Error] with a 411 Code instead of a
+// decoding error. A "Content-Length: 0" is allowed, so it can be
+// combined with [AllowEmptyBody].
+func RequireContentLength(require bool) Option {
+	return func(h *handler) {
+		h.requireContentLength = require
+	}
+}
+
+// AllowEmptyBody makes an empty request body decode as the
+// request type's zero value instead of being an error.
+// Bodies that are cut short are still an error.
+func AllowEmptyBody(allow bool) Option {
+	return func(h *handler) {
+		h.allowEmptyBody = allow
+	}
+}
+
+// Envelope makes the handler encode the value f returns instead
+// of the wrapped function's response, eg to follow an API
+// standard that wraps responses in an object:
+//
+//	jh.Envelope(func(ctx context.Context, resp any) any {
+//		return map[string]any{"data": resp, "meta": meta(ctx)}
+//	})
+//
+// It's only used for responses with a body that aren't an
+// [io.Reader] or [io.WriterTo]. Errors are left to the error func.
+func Envelope(f func(ctx context.Context, resp any) any) Option {
+	return func(h *handler) {
+		h.envelope = f
+	}
+}
+
+// BeforeWrite sets a function called with the wrapped function's
+// response before it's written, eg to set cache or Location headers
+// that depend on it. resp is nil for wrapped functions that only
+// return an error. An error returned by f is passed to the error
+// func instead of writing the response.
+func BeforeWrite(f func(ctx context.Context, w http.ResponseWriter, resp any) error) Option {
+	return func(h *handler) {
+		h.beforeWrite = f
+	}
+}
+
+// OperationID sets the stable name of the handler's operation, eg
+// "listWidgets". [OpenAPI] uses it as the operationId, it's in the
+// handler's [RouteInfo] and [Operation] returns it while serving
+// requests, which makes it a good label for logs and metrics.
+// Routes registered with a [Mux] without one get an ID derived
+// from their pattern, like "getWidgetsId" for "GET /widgets/{id}".
+func OperationID(id string) Option {
+	return func(h *handler) {
+		h.operationID = id
+	}
+}
+
+// Example attaches an example request and response to the handler
+// which [OpenAPI] includes in the document. Either can be nil.
+// A request of type T, or a response of type T for wrapped
+// functions returning a *T, must match the wrapped function's
+// types: [Handler] returns an error when they don't and
+// [HandlerFunc] panics.
+func Example(req, resp any) Option {
+	return func(h *handler) {
+		h.exampleReq = req
+		h.exampleResp = resp
+	}
+}
+
+// WithSchema validates JSON request bodies against the JSON Schema
+// schemaJSON before decoding them, for stricter contracts than
+// decoding alone enforces. The error func receives an [Error] with
+// a 400 Code whose Details map each invalid field, eg "items[0].id",
+// to the problem. The keywords that describe a document's
+// structure are supported; others, like format, are ignored.
+// [Handler] returns an error when the schema is invalid
+// and [HandlerFunc] panics.
+func WithSchema(schemaJSON []byte) Option {
+	return func(h *handler) {
+		h.schema, h.schemaErr = parseSchema(schemaJSON)
+	}
+}
+
+// ETags adds an ETag header to successful responses to GET and
+// HEAD requests. The tag is a hash of the encoded response unless
+// the response is an [ETagger]. Requests with a matching
+// If-None-Match header get a 304 Not Modified without a body.
+// Streamed [io.Reader] and [io.WriterTo] responses don't get ETags.
+func ETags(enable bool) Option {
+	return func(h *handler) {
+		h.etags = enable
+	}
+}
+
+// UseNumber makes JSON numbers decoded into interface values,
+// like the values of a map[string]any, a [encoding/json.Number]
+// instead of a float64, which can't hold every int64.
+// Numbers decoded into typed fields are always checked:
+// a fraction or a value that overflows an int field
+// is a 400 [Error] naming the field.
+// See [encoding/json.Decoder.UseNumber].
+func UseNumber(use bool) Option {
+	return func(h *handler) {
+		h.json.useNumber = use
+	}
+}
+
+// ContentType sets the Content-Type of JSON responses, eg
+// "application/vnd.myapi.v2+json", which is useful for versioning
+// APIs by media type. The default is application/json; charset=utf-8.
+// Requests are decoded as they would be without it.
+// Used with [NewMux] it applies to every route.
+func ContentType(contentType string) Option {
+	return func(h *handler) {
+		h.contentType = contentType
+	}
+}
+
+// BufferResponse makes the handler encode responses into a buffer
+// before writing them, so an encoding error can't leave a client
+// with a 200 and a truncated body, and sets their Content-Length.
+// Without it responses are encoded straight to the connection and
+// an error is only passed to the error func when the encoder fails
+// before writing anything, which encoding/json always does.
+// It's meant for codecs that stream their output. Streamed
+// [io.Reader] and [io.WriterTo] responses aren't buffered.
+func BufferResponse(buffer bool) Option {
+	return func(h *handler) {
+		h.bufferResponse = buffer
+	}
+}
+
+// EscapeHTML sets whether <, >, and & are escaped in JSON
+// responses. They are escaped by default.
+// See [encoding/json.Encoder.SetEscapeHTML].
+func EscapeHTML(escape bool) Option {
+	return func(h *handler) {
+		h.json.noEscapeHTML = !escape
+	}
+}
+
+// Indent makes JSON responses indented.
+// See [encoding/json.Encoder.SetIndent].
+func Indent(prefix, indent string) Option {
+	return func(h *handler) {
+		h.json.prefix = prefix
+		h.json.indent = indent
+	}
+}
+
+// MaxMemory sets the number of bytes of a multipart/form-data
+// request that are kept in memory. The rest of the parts
+// are stored on disk in temporary files. The default is 32 MB.
+func MaxMemory(n int64) Option {
+	return func(h *handler) {
+		h.maxMemory = n
+	}
+}
+
+// OnPanic sets the function that converts a value recovered
+// from a panicking wrapped function into the error passed to
+// the error func. stack is the panicking goroutine's stack.
+// By default the error is a [PanicError].
+func OnPanic(f func(ctx context.Context, v any, stack []byte) error) Option {
+	return func(h *handler) {
+		h.onPanic = f
+	}
+}
+
+// DefaultRecoverPanics is used by handlers that don't
+// use the [RecoverPanics] option.
+var DefaultRecoverPanics = true
+
+// RecoverPanics sets whether panics in wrapped functions are
+// recovered, which is the default, and passed to the error func.
+// Without recovery they propagate to net/http, or to the caller
+// of ServeHTTP, eg so tests fail loudly:
+//
+//	func TestMain(m *testing.M) {
+//		jh.DefaultRecoverPanics = false
+//		os.Exit(m.Run())
+//	}
+//
+// [OnPanic] has no effect then.
+func RecoverPanics(enable bool) Option {
+	return func(h *handler) {
+		h.recoverPanics = enable
+	}
+}
+
+// Timeout cancels the context passed to the wrapped function
+// after d. When the wrapped function returns an error caused by
+// the deadline, eg [context.DeadlineExceeded], the error func
+// receives an [Error] with a 504 Code instead.
+// Responses returned without an error are written as usual.
+func Timeout(d time.Duration) Option {
+	return func(h *handler) {
+		h.timeout = d
+	}
+}
+
+// TimeoutHeader lets clients bound the time spent on their
+// requests with a header holding a duration, eg
+// "X-Request-Timeout: 5s". The context passed to the wrapped
+// function is canceled after that long and deadline errors become
+// a 504 like they do with [Timeout]. When both are used the shorter
+// timeout applies. Invalid durations are ignored unless strict is
+// set, which makes them a 400 [Error].
+func TimeoutHeader(header string, strict bool) Option {
+	return func(h *handler) {
+		h.timeoutHeader = header
+		h.strictTimeoutHeader = strict
+	}
+}
@@ -0,0 +1,621 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoPoint(ctx context.Context, p point) (*point, error) {
+	return &p, nil
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	body := `{"x": 1, "y": 2}`
+	cases := []struct {
+		def  int64
+		opts []Option
+		want int
+	}{
+		{0, nil, 200},
+		{0, []Option{MaxBodyBytes(8)}, 413},
+		{0, []Option{MaxBodyBytes(int64(len(body)))}, 200},
+		{8, nil, 413},
+		{8, []Option{MaxBodyBytes(0)}, 200},
+	}
+	defer func(n int64) { DefaultMaxBodyBytes = n }(DefaultMaxBodyBytes)
+	for i, c := range cases {
+		DefaultMaxBodyBytes = c.def
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(body))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	body := `{"x": 1, "z": 2}`
+	cases := []struct {
+		def      bool
+		opts     []Option
+		want     int
+		wantBody string
+	}{
+		{false, nil, 200, "{\"x\":1,\"y\":0}\n"},
+		{false, []Option{DisallowUnknownFields(true)}, 400, "{\"message\":\"json: unknown field \\\"z\\\"\"}\n"},
+		{true, nil, 400, "{\"message\":\"json: unknown field \\\"z\\\"\"}\n"},
+		{true, []Option{DisallowUnknownFields(false)}, 200, "{\"x\":1,\"y\":0}\n"},
+	}
+	defer func(b bool) { DefaultDisallowUnknownFields = b }(DefaultDisallowUnknownFields)
+	for i, c := range cases {
+		DefaultDisallowUnknownFields = c.def
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(body))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if string(got) != c.wantBody {
+			t.Errorf("case %d: got = %q; want %q", i, got, c.wantBody)
+		}
+	}
+}
+
+type rating struct {
+	Stars int `json:"stars" jh:"required"`
+}
+
+func (r rating) Validate() error {
+	if r.Stars > 5 {
+		return errors.New("too many stars")
+	}
+	return nil
+}
+
+func TestValidationStatus(t *testing.T) {
+	rate := func(ctx context.Context, r rating) (*rating, error) {
+		return &r, nil
+	}
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"stars":3}`, 200},
+		{`{"stars":6}`, 422},
+		{`{}`, 422},
+		{`{"stars":"three"}`, 422},
+		{`{"stars":3,"color":"red"}`, 422},
+		{`{"stars":`, 400},
+		{``, 400},
+	}
+	h := HandlerFunc(rate, ValidationStatus(422), DisallowUnknownFields(true))
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if rec.Code != c.want {
+			t.Errorf("%q: got %d want %d", c.body, rec.Code, c.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	HandlerFunc(rate).ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"stars":6}`)))
+	if rec.Code != 400 {
+		t.Errorf("got %d want 400 by default", rec.Code)
+	}
+}
+
+func TestDisallowTrailingData(t *testing.T) {
+	cases := []struct {
+		body     string
+		disallow bool
+		want     int
+	}{
+		{`{"x":1}{"y":2}`, false, 200},
+		{`{"x":1}{"y":2}`, true, 400},
+		{`{"x":1}}`, true, 400},
+		{`{"x":1} x`, true, 400},
+		{"{\"x\":1}\n\t ", true, 200},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h := HandlerFunc(echoPoint, DisallowTrailingData(c.disallow))
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if rec.Code != c.want {
+			t.Errorf("%q %v: got %d want %d", c.body, c.disallow, rec.Code, c.want)
+		}
+		if c.want == 400 {
+			if got, want := rec.Body.String(), "{\"message\":\"unexpected data after the JSON value\"}\n"; got != want {
+				t.Errorf("%q: got %q want %q", c.body, got, want)
+			}
+		}
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	cases := []struct {
+		method, contentType, body string
+		opts                      []Option
+		want                      int
+	}{
+		{"POST", "text/plain", `{"x":1}`, nil, 200},
+		{"POST", "text/plain", `{"x":1}`, []Option{RequireContentType(true)}, 415},
+		{"POST", "", `{"x":1}`, []Option{RequireContentType(true)}, 415},
+		{"POST", "application/json", `{"x":1}`, []Option{RequireContentType(true)}, 200},
+		{"POST", "Application/JSON; charset=utf-8", `{"x":1}`, []Option{RequireContentType(true)}, 200},
+		{"POST", "application/xml", `<point><x>1</x></point>`, []Option{RequireContentType(true)}, 200},
+		{"GET", "", "", []Option{RequireContentType(true)}, 200},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestRequireContentLength(t *testing.T) {
+	cases := []struct {
+		method, contentLength, body string
+		chunked                     bool
+		opts                        []Option
+		want                        int
+	}{
+		{"POST", "", "", false, nil, 400},
+		{"POST", "", "", false, []Option{RequireContentLength(true)}, 411},
+		{"PUT", "", `{"x":1}`, true, []Option{RequireContentLength(true)}, 411},
+		{"POST", "7", `{"x":1}`, false, []Option{RequireContentLength(true)}, 200},
+		{"POST", "0", "", false, []Option{RequireContentLength(true)}, 400},
+		{"POST", "0", "", false, []Option{RequireContentLength(true), AllowEmptyBody(true)}, 200},
+		{"GET", "", "", false, []Option{RequireContentLength(true)}, 200},
+		{"DELETE", "", `{"x":1}`, true, []Option{RequireContentLength(true)}, 200},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		if c.contentLength != "" {
+			r.Header.Set("Content-Length", c.contentLength)
+		}
+		if c.chunked {
+			r.ContentLength = -1
+		}
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestAllowEmptyBody(t *testing.T) {
+	cases := []struct {
+		body     string
+		opts     []Option
+		want     int
+		wantBody string
+	}{
+		{"", nil, 400, "{\"message\":\"request body is empty\"}\n"},
+		{"", []Option{AllowEmptyBody(true)}, 200, "{\"x\":0,\"y\":0}\n"},
+		{" \n", []Option{AllowEmptyBody(true)}, 200, "{\"x\":0,\"y\":0}\n"},
+		{`{"x": 1`, []Option{AllowEmptyBody(true)}, 400, "{\"message\":\"malformed JSON: body ends unexpectedly\"}\n"},
+		{`{"x": 1}`, []Option{AllowEmptyBody(true)}, 200, "{\"x\":1,\"y\":0}\n"},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(echoPoint, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if string(got) != c.wantBody {
+			t.Errorf("case %d: got = %q; want %q", i, got, c.wantBody)
+		}
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	type page struct {
+		HTML string
+	}
+	show := func(ctx context.Context) (*page, error) {
+		return &page{"<b>&</b>"}, nil
+	}
+	cases := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "{\"HTML\":\"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"}\n"},
+		{[]Option{EscapeHTML(false)}, "{\"HTML\":\"<b>&</b>\"}\n"},
+		{[]Option{EscapeHTML(false), Indent("", "  ")}, "{\n  \"HTML\": \"<b>&</b>\"\n}\n"},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(show, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		got, _ := io.ReadAll(rec.Result().Body)
+		if string(got) != c.want {
+			t.Errorf("case %d: got = %q; want %q", i, got, c.want)
+		}
+	}
+}
+
+type badJSON struct{}
+
+func (badJSON) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("no JSON for you")
+}
+
+func TestEncodeError(t *testing.T) {
+	// streaming writes part of the body before failing
+	streaming := EncoderFunc(func(w io.Writer, v any) error {
+		io.WriteString(w, `{"partial":`)
+		return errors.New("stream broke")
+	})
+	cases := []struct {
+		f           any
+		opts        []Option
+		wantStatus  int
+		wantBody    string
+		wantLength  string
+		wantErrText string
+	}{
+		{func(ctx context.Context) (*struct{ C chan int }, error) {
+			return &struct{ C chan int }{}, nil
+		}, nil, 500, `{"error":"jh: encoding response: json: unsupported type: chan int"}`, "", "unsupported type"},
+		{func(ctx context.Context) (badJSON, error) {
+			return badJSON{}, nil
+		}, []Option{BufferResponse(true)}, 500, `{"error":"jh: encoding response: json: error calling MarshalJSON for type *jh.badJSON: no JSON for you"}`, "", "no JSON for you"},
+		{func(ctx context.Context) (*point, error) {
+			return &point{1, 2}, nil
+		}, []Option{Codec("application/json", streaming, nil)}, 200, `{"partial":`, "", "stream broke"},
+		{func(ctx context.Context) (*point, error) {
+			return &point{1, 2}, nil
+		}, []Option{Codec("application/json", streaming, nil), BufferResponse(true)}, 500, `{"error":"jh: encoding response: stream broke"}`, "", "stream broke"},
+		{func(ctx context.Context) (*point, error) {
+			return &point{1, 2}, nil
+		}, []Option{BufferResponse(true)}, 200, `{"x":1,"y":2}`, "14", ""},
+	}
+	for i, c := range cases {
+		var logged error
+		opts := append(c.opts, ErrFunc(func(ctx context.Context, w http.ResponseWriter, err error) {
+			logged = err
+			ErrHandler(ctx, w, err)
+		}))
+		h, err := Handler(c.f, nil, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Accept", "application/json")
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+		if got := rec.Header().Get("Content-Length"); c.wantLength != "" && got != c.wantLength {
+			t.Errorf("case %d: got Content-Length %q want %q", i, got, c.wantLength)
+		}
+		if c.wantStatus == 500 && (logged == nil || !strings.Contains(logged.Error(), c.wantErrText)) {
+			t.Errorf("case %d: error func got %v", i, logged)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	const v2 = "application/vnd.myapi.v2+json"
+	m := NewMux(ContentType(v2))
+	if err := m.Handle("POST /echo", echoPoint); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		h      http.Handler
+		accept string
+		want   string
+	}{
+		{HandlerFunc(echoPoint), "", "application/json; charset=utf-8"},
+		{HandlerFunc(echoPoint, ContentType(v2)), "", v2},
+		{HandlerFunc(echoPoint, ContentType(v2)), v2, v2},
+		{HandlerFunc(echoPoint, ContentType(v2)), "application/xml", "application/xml; charset=utf-8"},
+		{m, "", v2},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/echo", strings.NewReader(`{"x":1}`))
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("Accept", c.accept)
+		c.h.ServeHTTP(rec, r)
+		if rec.Code != 200 {
+			t.Fatalf("case %d: got %d %s", i, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Content-Type"); got != c.want {
+			t.Errorf("case %d: got %q want %q", i, got, c.want)
+		}
+	}
+}
+
+func TestBeforeWrite(t *testing.T) {
+	h := HandlerFunc(echoPoint, BeforeWrite(func(ctx context.Context, w http.ResponseWriter, resp any) error {
+		p := resp.(*point)
+		if p.X < 0 {
+			return BadRequest("x must be positive")
+		}
+		w.Header().Set("Location", fmt.Sprintf("/points/%d", p.X))
+		return nil
+	}))
+	cases := []struct {
+		body     string
+		status   int
+		location string
+	}{
+		{`{"x":1}`, 200, "/points/1"},
+		{`{"x":-1}`, 400, ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if rec.Code != c.status {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.status)
+		}
+		if got := rec.Header().Get("Location"); got != c.location {
+			t.Errorf("%s: got location %q want %q", c.body, got, c.location)
+		}
+	}
+}
+
+func TestTeeBody(t *testing.T) {
+	var got []byte
+	record := func(ctx context.Context, w http.ResponseWriter, err error) {
+		got = RawBody(ctx)
+		ErrHandler(ctx, w, err)
+	}
+	h := HandlerFunc(func(ctx context.Context, p point) (*point, error) {
+		got = RawBody(ctx)
+		return &p, nil
+	}, TeeBody(true), ErrFunc(record))
+
+	cases := []struct {
+		body    string
+		maxTee  int64
+		want    string
+		wantErr bool
+	}{
+		{`{"x":1}`, 1 << 20, `{"x":1}`, false},
+		{`{"x":"one"}`, 1 << 20, `{"x":"one"}`, true},
+		{`{"x":1,"y":2}`, 4, `{"x"`, false},
+	}
+	defer func(n int64) { MaxTeeBytes = n }(MaxTeeBytes)
+	for _, c := range cases {
+		MaxTeeBytes = c.maxTee
+		got = nil
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if (rec.Code != 200) != c.wantErr {
+			t.Errorf("%s: got %d", c.body, rec.Code)
+		}
+		if string(got) != c.want {
+			t.Errorf("%s: got %q want %q", c.body, got, c.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	HandlerFunc(func(ctx context.Context, p point) (*point, error) {
+		got = RawBody(ctx)
+		return &p, nil
+	}).ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if got != nil {
+		t.Errorf("got %q without TeeBody", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slow := func(ctx context.Context) (*struct{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return &struct{}{}, nil
+		}
+	}
+	fast := func(ctx context.Context) (*struct{}, error) {
+		return &struct{}{}, nil
+	}
+	cases := []struct {
+		f    any
+		want int
+	}{
+		{slow, 504},
+		{fast, 200},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(c.f, ErrHandler, Timeout(10*time.Millisecond))
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+	}
+}
+
+func TestTimeoutHeader(t *testing.T) {
+	var deadline time.Duration
+	f := func(ctx context.Context) (*struct{}, error) {
+		if d, ok := ctx.Deadline(); ok {
+			deadline = time.Until(d).Round(time.Second)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return &struct{}{}, nil
+		}
+	}
+	cases := []struct {
+		header       string
+		opts         []Option
+		want         int
+		wantDeadline time.Duration
+	}{
+		{"", []Option{TimeoutHeader("X-Request-Timeout", false)}, 200, 0},
+		{"10ms", []Option{TimeoutHeader("X-Request-Timeout", false)}, 504, 0},
+		{"10s", []Option{TimeoutHeader("X-Request-Timeout", false)}, 200, 10 * time.Second},
+		{"10s", []Option{TimeoutHeader("X-Request-Timeout", false), Timeout(3 * time.Second)}, 200, 3 * time.Second},
+		{"2s", []Option{Timeout(3 * time.Second), TimeoutHeader("X-Request-Timeout", false)}, 200, 2 * time.Second},
+		{"soon", []Option{TimeoutHeader("X-Request-Timeout", false)}, 200, 0},
+		{"-1s", []Option{TimeoutHeader("X-Request-Timeout", false)}, 200, 0},
+		{"soon", []Option{TimeoutHeader("X-Request-Timeout", true)}, 400, 0},
+		{"10ms", nil, 200, 0},
+	}
+	for i, c := range cases {
+		deadline = 0
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.header != "" {
+			r.Header.Set("X-Request-Timeout", c.header)
+		}
+		h, _ := Handler(f, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if c.want == 200 && deadline != c.wantDeadline {
+			t.Errorf("case %d: got deadline %s want %s", i, deadline, c.wantDeadline)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	env := Envelope(func(ctx context.Context, resp any) any {
+		return map[string]any{"data": resp, "meta": map[string]string{"path": Request(ctx).URL.Path}}
+	})
+	cases := []struct {
+		f        any
+		want     int
+		wantBody string
+	}{
+		{echoPoint, 200, `{"data":{"x":1,"y":2},"meta":{"path":"/p"}}`},
+		{func(ctx context.Context, p point) (*created, error) { return &created{}, nil }, 201, `{"data":{"ID":0},"meta":{"path":"/p"}}`},
+		{func(ctx context.Context, p point) error { return nil }, 204, ``},
+		{func(ctx context.Context, p point) (*point, error) { return nil, NotFound("no point") }, 404, `{"message":"no point"}`},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/p", strings.NewReader(`{"x":1,"y":2}`))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(c.f, ErrHandler, env)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	type payment struct {
+		Cents int64          `json:"cents"`
+		Count int8           `json:"count"`
+		Extra map[string]any `json:"extra"`
+	}
+	describe := func(ctx context.Context, p payment) (*string, error) {
+		s := fmt.Sprintf("%d %T %v", p.Cents, p.Extra["id"], p.Extra["id"])
+		return &s, nil
+	}
+	cases := []struct {
+		body     string
+		opts     []Option
+		want     int
+		wantBody string
+	}{
+		{`{"cents":9007199254740993,"extra":{"id":9007199254740993}}`, nil, 200, `"9007199254740993 float64 9.007199254740992e+15"`},
+		{`{"cents":9007199254740993,"extra":{"id":9007199254740993}}`, []Option{UseNumber(true)}, 200, `"9007199254740993 json.Number 9007199254740993"`},
+		{`{"cents":1.5}`, []Option{UseNumber(true)}, 400, `{"message":"field \"cents\" must be an integer, not a number"}`},
+		{`{"count":300}`, []Option{UseNumber(true)}, 400, `{"message":"field \"count\" must be an integer, not a number"}`},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		h, _ := Handler(describe, ErrHandler, c.opts...)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	boom := func(ctx context.Context) error { panic("boom") }
+	serve := func(h http.Handler) (v any) {
+		defer func() { v = recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		return nil
+	}
+
+	h, _ := Handler(boom, ErrHandler)
+	if v := serve(h); v != nil {
+		t.Errorf("got panic %v want recovered", v)
+	}
+	h, _ = Handler(boom, ErrHandler, RecoverPanics(false))
+	if v := serve(h); v != "boom" {
+		t.Errorf("got %v want panic boom", v)
+	}
+
+	defer func(enable bool) { DefaultRecoverPanics = enable }(DefaultRecoverPanics)
+	DefaultRecoverPanics = false
+	if v := serve(HandlerFunc(func(ctx context.Context, _ struct{}) (*struct{}, error) { panic("boom") })); v != "boom" {
+		t.Errorf("got %v want panic boom", v)
+	}
+	h, _ = Handler(boom, ErrHandler, RecoverPanics(true))
+	if v := serve(h); v != nil {
+		t.Errorf("got panic %v want recovered", v)
+	}
+}
@@ -0,0 +1,58 @@
+package jh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Paginate sets the headers of a page of a list of total items
+// starting at offset with up to limit items. X-Total-Count is
+// set to total and the Link header gets first, prev, next and last
+// links, as applicable, to the request's URL with its limit and
+// offset query parameters replaced. It's called from a wrapped
+// function whose request binds those parameters:
+//
+//	type listUsers struct {
+//		Limit  int `query:"limit" default:"20"`
+//		Offset int `query:"offset"`
+//	}
+//
+//	func list(ctx context.Context, r listUsers) ([]user, error) {
+//		users, total, err := db.Users(ctx, r.Limit, r.Offset)
+//		jh.Paginate(ctx, total, r.Limit, r.Offset)
+//		return users, err
+//	}
+//
+// Only X-Total-Count is set when limit isn't positive.
+func Paginate(ctx context.Context, total, limit, offset int) {
+	hdr := ResponseWriter(ctx).Header()
+	hdr.Set("X-Total-Count", strconv.Itoa(total))
+	if limit <= 0 {
+		return
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	u := *Request(ctx).URL
+	link := func(rel string, offset int) string {
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+	links := []string{link("first", 0)}
+	if offset > 0 {
+		links = append(links, link("prev", max(offset-limit, 0)))
+	}
+	if offset+limit < total {
+		links = append(links, link("next", offset+limit))
+	}
+	if total > 0 {
+		links = append(links, link("last", (total-1)/limit*limit))
+	}
+	hdr.Set("Link", strings.Join(links, ", "))
+}
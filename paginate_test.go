@@ -0,0 +1,55 @@
+package jh
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	type list struct {
+		Limit  int `query:"limit" default:"10"`
+		Offset int `query:"offset"`
+	}
+	h, _ := Handler(func(ctx context.Context, l list) ([]int, error) {
+		Paginate(ctx, 25, l.Limit, l.Offset)
+		return []int{}, nil
+	}, ErrHandler)
+
+	cases := []struct {
+		target, wantLink string
+	}{
+		{
+			"/items?sort=name",
+			`</items?limit=10&offset=0&sort=name>; rel="first", ` +
+				`</items?limit=10&offset=10&sort=name>; rel="next", ` +
+				`</items?limit=10&offset=20&sort=name>; rel="last"`,
+		},
+		{
+			"/items?offset=15&limit=10",
+			`</items?limit=10&offset=0>; rel="first", ` +
+				`</items?limit=10&offset=5>; rel="prev", ` +
+				`</items?limit=10&offset=20>; rel="last"`,
+		},
+		{
+			"/items?offset=5&limit=5",
+			`</items?limit=5&offset=0>; rel="first", ` +
+				`</items?limit=5&offset=0>; rel="prev", ` +
+				`</items?limit=5&offset=10>; rel="next", ` +
+				`</items?limit=5&offset=20>; rel="last"`,
+		},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", c.target, nil)
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, r)
+		if got := rec.Header().Get("Link"); got != c.wantLink {
+			t.Errorf("%s: got Link\n%s\nwant\n%s", c.target, got, c.wantLink)
+		}
+		if got := rec.Header().Get("X-Total-Count"); got != "25" {
+			t.Errorf("%s: got X-Total-Count %q want %q", c.target, got, "25")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package jh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// HandlerWithParams is like [Handler] for wrapped functions that
+// only take a context but need values from the request's path,
+// query, headers or cookies, eg GET endpoints without a body:
+//
+//	type listParams struct {
+//		Limit int    `query:"limit" default:"20"`
+//		After string `query:"after"`
+//	}
+//
+//	h, err := jh.HandlerWithParams(listParams{}, func(ctx context.Context) ([]widget, error) {
+//		p := jh.Params[listParams](ctx)
+//		return listWidgets(ctx, p.Limit, p.After)
+//	}, nil)
+//
+// params is a struct, or a pointer to one, whose tagged fields are
+// bound as a request's would be. Default tags and `jh:"required"`
+// apply and a params type that is a [Validator] is validated.
+// The body is never read. The wrapped function gets the bound
+// values with [Params]. [OpenAPI] describes them as parameters.
+func HandlerWithParams(
+	params any,
+	wrappedFunc any,
+	errFunc func(context.Context, http.ResponseWriter, error),
+	opts ...Option,
+) (http.Handler, error) {
+	t := reflect.TypeOf(params)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("jh: handler with params: params is %T, want a struct", params)
+	}
+	h, err := reflectHandler(wrappedFunc, errFunc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if h.newReq != nil {
+		ft := reflect.TypeOf(wrappedFunc)
+		return nil, signatureError(ft, ErrTooManyArgs, "has 2 args, want 1 with params")
+	}
+	h.setRequest(t)
+	h.paramsOnly = true
+	// the wrapped function may keep ctx, and the values with it
+	h.resetReq = nil
+	call := h.call
+	h.call = func(ctx context.Context, req any) (any, error) {
+		return call(context.WithValue(ctx, paramsKey, req), nil)
+	}
+	if err := h.checkOptions(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Params returns the values bound for a [HandlerWithParams]
+// handler, where T is the params type without a pointer.
+// It returns T's zero value elsewhere.
+func Params[T any](ctx context.Context) T {
+	p, _ := ctx.Value(paramsKey).(*T)
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,96 @@
+package jh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type listParams struct {
+	Limit int    `query:"limit" default:"20"`
+	After string `query:"after"`
+	Org   string `path:"org"`
+}
+
+func TestHandlerWithParams(t *testing.T) {
+	h, err := HandlerWithParams(listParams{}, func(ctx context.Context) (*listParams, error) {
+		p := Params[listParams](ctx)
+		return &p, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/orgs/{org}/widgets", h)
+
+	cases := []struct {
+		method, target, body string
+		want                 listParams
+	}{
+		{"GET", "/orgs/acme/widgets", "", listParams{Limit: 20, Org: "acme"}},
+		{"GET", "/orgs/acme/widgets?limit=5&after=w7", "", listParams{Limit: 5, After: "w7", Org: "acme"}},
+		{"POST", "/orgs/acme/widgets?limit=5", `{"Limit":1}`, listParams{Limit: 5, Org: "acme"}},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, strings.NewReader(c.body)))
+		if rec.Code != 200 {
+			t.Errorf("%s %s: got %d want 200", c.method, c.target, rec.Code)
+			continue
+		}
+		var got listParams
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s %s: got %+v want %+v", c.method, c.target, got, c.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/orgs/acme/widgets?limit=x", nil))
+	if rec.Code != 400 {
+		t.Errorf("got %d want 400", rec.Code)
+	}
+
+	info, _ := Info(h)
+	if info.NumIn != 1 || info.Request != reflect.TypeOf(listParams{}) {
+		t.Errorf("got %+v", info)
+	}
+}
+
+func TestHandlerWithParamsErrors(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	cases := []struct {
+		params, f any
+		want      error
+	}{
+		{nil, ok, nil},
+		{"limit", ok, nil},
+		{&listParams{}, func(ctx context.Context, r listParams) error { return nil }, ErrTooManyArgs},
+		{listParams{}, func() error { return nil }, ErrTooFewArgs},
+	}
+	for i, c := range cases {
+		_, err := HandlerWithParams(c.params, c.f, nil)
+		if err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("case %d: got %v want %v", i, err, c.want)
+		}
+	}
+	if _, err := HandlerWithParams(&listParams{}, ok, nil, Example(listParams{Limit: 1}, nil)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	if got := Params[listParams](context.Background()); got != (listParams{}) {
+		t.Errorf("got %+v want zero value", got)
+	}
+}
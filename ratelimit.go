@@ -0,0 +1,134 @@
+package jh
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Limiter decides whether a request identified by key may
+// proceed. When it may not, retryAfter is how long the client
+// should wait before trying again. Limiters are used by
+// [RateLimitWith] and must be safe for concurrent use.
+type Limiter interface {
+	Allow(key string) (ok bool, retryAfter time.Duration)
+}
+
+// RateLimit returns middleware that limits each key to rps requests
+// per second with bursts of up to burst requests using an in memory
+// token bucket. keyFn identifies the client, eg by API key, and
+// the client's IP address is used when it's nil. Limited requests
+// get a 429 [Error] with a Retry-After header.
+//
+//	h := Chain(HandlerFunc(search), RateLimit(5, 10, nil))
+func RateLimit(rps float64, burst int, keyFn func(*http.Request) string) func(http.Handler) http.Handler {
+	return RateLimitWith(NewTokenBuckets(rps, burst), keyFn)
+}
+
+// RateLimitWith is like [RateLimit] but uses l to decide
+// which requests proceed, eg to share limits between
+// servers using an external store.
+func RateLimitWith(l Limiter, keyFn func(*http.Request) string) func(http.Handler) http.Handler {
+	if keyFn == nil {
+		keyFn = remoteIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retry := l.Allow(keyFn(r))
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			secs := int(math.Ceil(retry.Seconds()))
+			if secs < 1 {
+				secs = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			ErrHandler(r.Context(), w, Error{
+				Code:    http.StatusTooManyRequests,
+				Message: "rate limit exceeded",
+			})
+		})
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// NewTokenBuckets returns an in memory [Limiter] with a token
+// bucket for each key. Buckets hold up to burst tokens and are
+// refilled at rps tokens per second. Each request takes a token.
+// Buckets that have refilled are forgotten.
+func NewTokenBuckets(rps float64, burst int) Limiter {
+	return &tokenBuckets{
+		rps:     rps,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+type tokenBuckets struct {
+	rps, burst float64
+	now        func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (tb *tokenBuckets) Allow(key string) (bool, time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := tb.now()
+	tb.sweep(now)
+
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.burst, last: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = tb.refill(b, now)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if tb.rps <= 0 {
+		return false, time.Minute
+	}
+	wait := (1 - b.tokens) / tb.rps
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+func (tb *tokenBuckets) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*tb.rps
+	return math.Min(tokens, tb.burst)
+}
+
+// sweep forgets full buckets at most once a minute
+// so the map doesn't grow with every client ever seen.
+func (tb *tokenBuckets) sweep(now time.Time) {
+	if now.Sub(tb.lastSweep) < time.Minute {
+		return
+	}
+	tb.lastSweep = now
+	for key, b := range tb.buckets {
+		if tb.refill(b, now) >= tb.burst {
+			delete(tb.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	var (
+		now = time.Unix(0, 0)
+		tb  = NewTokenBuckets(2, 2).(*tokenBuckets)
+	)
+	tb.now = func() time.Time { return now }
+	ok, _ := Handler(func(ctx context.Context) (*struct{}, error) {
+		return &struct{}{}, nil
+	}, ErrHandler)
+	h := RateLimitWith(tb, func(r *http.Request) string {
+		return r.Header.Get("X-Api-Key")
+	})(ok)
+
+	cases := []struct {
+		key       string
+		advance   time.Duration
+		want      int
+		wantRetry string
+	}{
+		{"a", 0, 200, ""},
+		{"a", 0, 200, ""},
+		{"a", 0, 429, "1"},
+		{"b", 0, 200, ""},
+		{"a", 500 * time.Millisecond, 200, ""},
+		{"a", 0, 429, "1"},
+		{"a", time.Minute, 200, ""},
+	}
+	for i, c := range cases {
+		now = now.Add(c.advance)
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		r.Header.Set("X-Api-Key", c.key)
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.want)
+		}
+		if got := rec.Header().Get("Retry-After"); got != c.wantRetry {
+			t.Errorf("case %d: got Retry-After %q want %q", i, got, c.wantRetry)
+		}
+		if c.want == 429 {
+			want := `{"message":"rate limit exceeded"}`
+			if got := strings.TrimSpace(rec.Body.String()); got != want {
+				t.Errorf("case %d: got = %s; want %s", i, got, want)
+			}
+		}
+	}
+	if len(tb.buckets) != 1 {
+		t.Errorf("got %d buckets want 1 after sweeping", len(tb.buckets))
+	}
+
+	// keyed by IP by default
+	h = RateLimit(1, 1, nil)(ok)
+	for i, want := range []int{200, 429} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != want {
+			t.Errorf("request %d: got %d want %d", i, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package jh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header that [RequestIDHandler]
+// reads request IDs from and writes them to.
+var RequestIDHeader = "X-Request-ID"
+
+// RequestIDHandler gives every request an ID that wrapped
+// functions and error funcs can get using [RequestID].
+// The ID is taken from the request's [RequestIDHeader] when it
+// has a reasonable value and is generated otherwise.
+// It's echoed in the response's RequestIDHeader.
+func RequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID returns the ID that [RequestIDHandler]
+// assigned to the request. It returns "" when
+// the request wasn't served by RequestIDHandler.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID reports whether id is short
+// and only has printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var gotID, gotErrID string
+	h, _ := Handler(func(ctx context.Context) (*struct{}, error) {
+		gotID = RequestID(ctx)
+		return nil, Error{Code: 400, Message: "m"}
+	}, func(ctx context.Context, w http.ResponseWriter, err error) {
+		gotErrID = RequestID(ctx)
+		ErrHandler(ctx, w, err)
+	})
+	h = RequestIDHandler(h)
+
+	cases := []struct {
+		incoming string
+		keep     bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"has space", false},
+		{strings.Repeat("a", 129), false},
+	}
+	for _, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/", nil)
+			rec = httptest.NewRecorder()
+		)
+		if c.incoming != "" {
+			r.Header.Set("X-Request-ID", c.incoming)
+		}
+		h.ServeHTTP(rec, r)
+		echoed := rec.Header().Get("X-Request-ID")
+		if echoed == "" || echoed != gotID || echoed != gotErrID {
+			t.Errorf("%q: echoed %q, handler %q, error func %q", c.incoming, echoed, gotID, gotErrID)
+		}
+		if (echoed == c.incoming) != c.keep {
+			t.Errorf("%q: echoed %q", c.incoming, echoed)
+		}
+	}
+
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("got %q want empty", id)
+	}
+}
@@ -0,0 +1,136 @@
+package jh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// hasRequired reports whether t, or a struct it refers to,
+// has a body field tagged `jh:"required"`.
+func hasRequired(t reflect.Type) bool {
+	return hasRequiredSeen(t, map[reflect.Type]bool{})
+}
+
+func hasRequiredSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if isParam(sf) {
+			continue
+		}
+		if isRequired(sf) || hasRequiredSeen(sf.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequired returns an [Error] with a 400 Code naming the
+// fields tagged `jh:"required"` that are missing from the
+// JSON body data. Fields that are null count as missing.
+// Nested fields are named like "address.city" and "items[0].id".
+func checkRequired(t reflect.Type, data []byte) error {
+	var raw json.RawMessage
+	json.NewDecoder(bytes.NewReader(data)).Decode(&raw)
+	missing := missingRequired(t, raw, "")
+	if len(missing) == 0 {
+		return nil
+	}
+	details := make(map[string]any, len(missing))
+	quoted := make([]string, len(missing))
+	for i, name := range missing {
+		details[name] = "required"
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	msg := "missing required field "
+	if len(missing) > 1 {
+		msg = "missing required fields "
+	}
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: msg + strings.Join(quoted, ", "),
+		Details: details,
+	}
+}
+
+func missingRequired(t reflect.Type, raw json.RawMessage, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		json.Unmarshal(raw, &obj)
+		return missingFields(t, obj, path)
+	case reflect.Slice, reflect.Array:
+		var elems []json.RawMessage
+		if json.Unmarshal(raw, &elems) != nil {
+			return nil
+		}
+		var missing []string
+		for i, e := range elems {
+			missing = append(missing, missingRequired(t.Elem(), e, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return missing
+	}
+	return nil
+}
+
+func missingFields(t reflect.Type, obj map[string]json.RawMessage, path string) []string {
+	var missing []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if (!sf.IsExported() && !sf.Anonymous) || isParam(sf) {
+			continue
+		}
+		tag, _ := sf.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// encoding/json promotes the fields of embedded structs
+			missing = append(missing, missingFields(ft, obj, path)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		full := name
+		if path != "" {
+			full = path + "." + name
+		}
+		val, ok := lookupKey(obj, name)
+		if !ok || string(bytes.TrimSpace(val)) == "null" {
+			if isRequired(sf) {
+				missing = append(missing, full)
+			}
+			continue
+		}
+		missing = append(missing, missingRequired(sf.Type, val, full)...)
+	}
+	return missing
+}
+
+// lookupKey finds name in obj like [findKey].
+func lookupKey(obj map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	key, ok := findKey(obj, name)
+	return obj[key], ok
+}
@@ -0,0 +1,67 @@
+package jh
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" jh:"required"`
+	Zip  string `json:"zip"`
+}
+
+type Meta struct {
+	Source string `json:"source" jh:"required"`
+}
+
+type signup struct {
+	Meta
+	Name    string    `json:"name" jh:"required"`
+	Age     *int      `json:"age" jh:"required"`
+	Address *address  `json:"address"`
+	Others  []address `json:"others"`
+	Tenant  string    `header:"X-Tenant-ID"`
+}
+
+func TestRequired(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, s signup) error {
+		return nil
+	}, ErrHandler)
+
+	cases := []struct {
+		contentType, body string
+		wantStatus        int
+		wantBody          string
+	}{
+		{"", `{"source":"web","name":"","age":0}`, 204, ``},
+		{"", `{"Source":"web","NAME":"x","age":1,"address":{"city":"y"}}`, 204, ``},
+		{"", `{"source":"web","age":1}`, 400, `{"message":"missing required field \"name\"","details":{"name":"required"}}`},
+		{"", `{"source":"web","name":"x","age":null}`, 400, `{"message":"missing required field \"age\"","details":{"age":"required"}}`},
+		{"", `{"name":"x","age":1}`, 400, `{"message":"missing required field \"source\"","details":{"source":"required"}}`},
+		{
+			"", `{"source":"web","name":"x","age":1,"address":{"zip":"1"},"others":[{"city":"a"},{}]}`,
+			400, `{"message":"missing required fields \"address.city\", \"others[1].city\"","details":{"address.city":"required","others[1].city":"required"}}`,
+		},
+		{"application/xml", `<signup><Name>x</Name></signup>`, 204, ``},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			rec = httptest.NewRecorder()
+		)
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		got, _ := io.ReadAll(rec.Result().Body)
+		if strings.TrimSpace(string(got)) != c.wantBody {
+			t.Errorf("case %d: got = %s; want %s", i, got, c.wantBody)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package jh
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// Response is a response value that sets the status and headers
+// of a successful response along with its body, as an alternative
+// to [WithStatus] and [ResponseWriter]:
+//
+//	func create(ctx context.Context, r newWidget) (*jh.Response[widget], error) {
+//		w, err := widgets.Create(ctx, r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return &jh.Response[widget]{
+//			Status: http.StatusCreated,
+//			Header: http.Header{"Location": {"/widgets/" + w.ID}},
+//			Body:   w,
+//		}, nil
+//	}
+//
+// Body is written like other response values are, so a nil Body
+// is a 204 No Content unless Status is set. A zero Status leaves
+// the status to [WithStatus] or Body's [StatusCoder] and a set
+// one takes precedence over them. Header's values replace those
+// of the same headers set before and are dropped by [Batch].
+// [Info] and [OpenAPI] describe Body's type as the response type.
+type Response[T any] struct {
+	Status int
+	Header http.Header
+	Body   T
+}
+
+func (r Response[T]) response() (int, http.Header, any) {
+	return r.Status, r.Header, r.Body
+}
+
+// responder is implemented by any [Response].
+type responder interface {
+	response() (status int, header http.Header, body any)
+}
+
+var responderType = reflect.TypeOf((*responder)(nil)).Elem()
+
+// setRespType records t, the wrapped function's response type,
+// or the Body type when t is a [Response] or a pointer to one.
+func (h *handler) setRespType(t reflect.Type) {
+	if t.Implements(responderType) {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if sf, ok := t.FieldByName("Body"); ok {
+			t = sf.Type
+		}
+	}
+	h.respType = t
+}
+
+// unwrapResponse returns the body of resp when it's a
+// [Response], after recording its status in st.
+// The headers to set are returned with it.
+func unwrapResponse(st *state, resp any) (any, http.Header) {
+	rs, ok := resp.(responder)
+	if !ok {
+		return resp, nil
+	}
+	status, header, body := rs.response()
+	if status != 0 {
+		st.status = status
+	}
+	if body != nil && isNil(reflect.ValueOf(body)) {
+		body = nil
+	}
+	return body, header
+}
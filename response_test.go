@@ -0,0 +1,81 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, p point) (*Response[point], error) {
+		switch {
+		case p.X < 0:
+			return nil, nil
+		case p.X == 0:
+			return &Response[point]{Status: http.StatusAccepted}, nil
+		case p.Y == 0:
+			return &Response[point]{Body: p}, nil
+		}
+		ResponseWriter(ctx).Header().Set("X-Point", "old")
+		WithStatus(ctx, http.StatusTeapot)
+		return &Response[point]{
+			Status: http.StatusCreated,
+			Header: http.Header{"x-point": {"new"}, "Location": {"/points/1"}},
+			Body:   p,
+		}, nil
+	})
+	cases := []struct {
+		body       string
+		wantStatus int
+		wantHeader http.Header
+		wantBody   string
+	}{
+		{`{"x":1,"y":2}`, 201, http.Header{"X-Point": {"new"}, "Location": {"/points/1"}}, `{"x":1,"y":2}`},
+		{`{"x":1,"y":0}`, 200, http.Header{"X-Point": nil}, `{"x":1,"y":0}`},
+		{`{"x":0,"y":0}`, 202, nil, `{"x":0,"y":0}`},
+		{`{"x":-1,"y":0}`, 204, nil, ``},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/points", strings.NewReader(c.body)))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+		}
+		for k, want := range c.wantHeader {
+			if got := rec.Header().Values(k); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: got %s %q want %q", c.body, k, got, want)
+			}
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.body, got, c.wantBody)
+		}
+	}
+
+	info, _ := Info(h)
+	if info.Response != reflect.TypeOf(point{}) {
+		t.Errorf("got response type %v want point", info.Response)
+	}
+	rh, err := Handler(func(ctx context.Context) (Response[[]point], error) {
+		return Response[[]point]{Body: []point{{X: 1}}}, nil
+	}, nil, Example(nil, []point{{X: 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := Info(rh); info.Response != reflect.TypeOf([]point(nil)) {
+		t.Errorf("got response type %v want []point", info.Response)
+	}
+}
+
+func TestResponseNilBody(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, _ struct{}) (*Response[*point], error) {
+		return &Response[*point]{Header: http.Header{"X-Point": {"none"}}}, nil
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 204 || rec.Body.Len() != 0 || rec.Header().Get("X-Point") != "none" {
+		t.Errorf("got %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+}
@@ -0,0 +1,488 @@
+package jh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A jsonSchema validates JSON documents against a JSON Schema.
+// It supports the keywords that describe a document's
+// structure, which is what most API schemas use:
+//
+//	type, enum, const, properties, required, additionalProperties,
+//	items, minItems, maxItems, uniqueItems, minimum, maximum,
+//	exclusiveMinimum, exclusiveMaximum, multipleOf, minLength,
+//	maxLength, pattern, allOf, anyOf, oneOf, not and $ref
+//
+// $refs must point into the schema, eg "#/$defs/address".
+// Other keywords, like format, are ignored.
+type jsonSchema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+func parseSchema(data []byte) (*jsonSchema, error) {
+	var root any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("jh: schema: %w", err)
+	}
+	s := &jsonSchema{root: root, patterns: map[string]*regexp.Regexp{}}
+	if err := s.compile(root); err != nil {
+		return nil, fmt.Errorf("jh: schema: %w", err)
+	}
+	return s, nil
+}
+
+// compile checks the schema node n and compiles its patterns.
+func (s *jsonSchema) compile(n any) error {
+	switch n := n.(type) {
+	case bool:
+		return nil
+	case map[string]any:
+		if p, ok := n["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return err
+			}
+			s.patterns[p] = re
+		}
+		if ref, ok := n["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return err
+			}
+		}
+		for k, v := range n {
+			switch k {
+			case "enum", "const", "required", "default", "examples", "example":
+				// values, not schemas
+				continue
+			case "properties", "patternProperties", "$defs", "definitions":
+				// names, which can be anything, mapped to schemas
+				m, _ := v.(map[string]any)
+				for _, sub := range m {
+					if err := s.compile(sub); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := s.compile(v); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if err := s.compile(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the node that the local $ref points to.
+func (s *jsonSchema) resolve(ref string) (any, error) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	n := s.root
+	if ptr == "" {
+		return n, nil
+	}
+	for _, tok := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch v := n.(type) {
+		case map[string]any:
+			n, ok = v[tok]
+		case []any:
+			i, err := strconv.Atoi(tok)
+			ok = err == nil && i >= 0 && i < len(v)
+			if ok {
+				n = v[i]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return n, nil
+}
+
+// check returns an [Error] with a 400 Code when the JSON document
+// data doesn't match s. Its Details map the paths of the invalid
+// values, named like checkRequired names them, to the problems.
+// Malformed documents are left to the decoder.
+func (s *jsonSchema) check(data []byte) error {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return nil
+	}
+	var errs schemaErrors
+	s.validate(s.root, v, "", &errs, 0)
+	if len(errs) == 0 {
+		return nil
+	}
+	details := make(map[string]any, len(errs))
+	for _, e := range errs {
+		if prev, ok := details[e.path]; ok {
+			details[e.path] = prev.(string) + "; " + e.msg
+			continue
+		}
+		details[e.path] = e.msg
+	}
+	first := errs[0]
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid %s: %s", first.path, first.msg),
+		Details: details,
+	}
+}
+
+type schemaError struct {
+	path, msg string
+}
+
+type schemaErrors []schemaError
+
+func (errs *schemaErrors) add(path, format string, args ...any) {
+	if path == "" {
+		path = "body"
+	}
+	*errs = append(*errs, schemaError{path, fmt.Sprintf(format, args...)})
+}
+
+// maxRefs bounds the $refs followed in a row, without
+// validating a part of the value, so schemas whose
+// $refs form a cycle terminate. Validating a property
+// or an item starts the count again.
+const maxRefs = 64
+
+// validate adds the ways v doesn't match the schema node n to
+// errs. refs is the number of $refs followed in a row to get to n.
+func (s *jsonSchema) validate(n, v any, path string, errs *schemaErrors, refs int) {
+	if refs > maxRefs {
+		errs.add(path, "schema has a cycle of $refs")
+		return
+	}
+	var m map[string]any
+	switch n := n.(type) {
+	case bool:
+		if !n {
+			errs.add(path, "not allowed")
+		}
+		return
+	case map[string]any:
+		m = n
+	default:
+		return
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		target, _ := s.resolve(ref)
+		s.validate(target, v, path, errs, refs+1)
+	}
+	if t, ok := m["type"]; ok && !matchesType(t, v) {
+		errs.add(path, "must be %s", typeNames(t))
+		return
+	}
+	if enum, ok := m["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs.add(path, "must be one of %s", jsonList(enum))
+		}
+	}
+	if c, ok := m["const"]; ok && !jsonEqual(c, v) {
+		errs.add(path, "must be %s", jsonString(c))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		s.validateObject(m, v, path, errs)
+	case []any:
+		s.validateArray(m, v, path, errs)
+	case string:
+		n := utf8.RuneCountInString(v)
+		if min, ok := schemaInt(m, "minLength"); ok && n < min {
+			errs.add(path, "must be at least %d characters", min)
+		}
+		if max, ok := schemaInt(m, "maxLength"); ok && n > max {
+			errs.add(path, "must be at most %d characters", max)
+		}
+		if p, ok := m["pattern"].(string); ok && !s.patterns[p].MatchString(v) {
+			errs.add(path, "must match %q", p)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if min, ok := schemaNumber(m, "minimum"); ok && f < min {
+			errs.add(path, "must be >= %v", min)
+		}
+		if max, ok := schemaNumber(m, "maximum"); ok && f > max {
+			errs.add(path, "must be <= %v", max)
+		}
+		if min, ok := schemaNumber(m, "exclusiveMinimum"); ok && f <= min {
+			errs.add(path, "must be > %v", min)
+		}
+		if max, ok := schemaNumber(m, "exclusiveMaximum"); ok && f >= max {
+			errs.add(path, "must be < %v", max)
+		}
+		if d, ok := schemaNumber(m, "multipleOf"); ok && d > 0 {
+			if q := f / d; math.Abs(q-math.Round(q)) > 1e-9 {
+				errs.add(path, "must be a multiple of %v", d)
+			}
+		}
+	}
+
+	if all, ok := m["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.validate(sub, v, path, errs, refs)
+		}
+	}
+	if some, ok := m["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range some {
+			if s.valid(sub, v, path, refs) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs.add(path, "must match a schema in anyOf")
+		}
+	}
+	if one, ok := m["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range one {
+			if s.valid(sub, v, path, refs) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			errs.add(path, "must match exactly one schema in oneOf")
+		}
+	}
+	if not, ok := m["not"]; ok && s.valid(not, v, path, refs) {
+		errs.add(path, "must not match the schema in not")
+	}
+}
+
+// valid reports whether v matches the schema node n.
+func (s *jsonSchema) valid(n, v any, path string, refs int) bool {
+	var errs schemaErrors
+	s.validate(n, v, path, &errs, refs)
+	return len(errs) == 0
+}
+
+func (s *jsonSchema) validateObject(m, obj map[string]any, path string, errs *schemaErrors) {
+	if required, ok := m["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				errs.add(joinPath(path, name), "required")
+			}
+		}
+	}
+	props, _ := m["properties"].(map[string]any)
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if p, ok := props[k]; ok {
+			s.validate(p, obj[k], joinPath(path, k), errs, 0)
+			continue
+		}
+		if add, ok := m["additionalProperties"]; ok {
+			if add == false {
+				errs.add(joinPath(path, k), "unknown field")
+				continue
+			}
+			s.validate(add, obj[k], joinPath(path, k), errs, 0)
+		}
+	}
+}
+
+func (s *jsonSchema) validateArray(m map[string]any, arr []any, path string, errs *schemaErrors) {
+	if min, ok := schemaInt(m, "minItems"); ok && len(arr) < min {
+		errs.add(path, "must have at least %d items", min)
+	}
+	if max, ok := schemaInt(m, "maxItems"); ok && len(arr) > max {
+		errs.add(path, "must have at most %d items", max)
+	}
+	if m["uniqueItems"] == true {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					errs.add(fmt.Sprintf("%s[%d]", path, i), "duplicates item %d", j)
+				}
+			}
+		}
+	}
+	if items, ok := m["items"]; ok {
+		for i, e := range arr {
+			s.validate(items, e, fmt.Sprintf("%s[%d]", path, i), errs, 0)
+		}
+	}
+}
+
+// joinPath names the field name of the object at path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesType(t, v any) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, v)
+	case []any:
+		for _, t := range t {
+			if name, ok := t.(string); ok && isType(name, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isType(name string, v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case []any:
+		return name == "array"
+	case map[string]any:
+		return name == "object"
+	case json.Number:
+		if name == "number" {
+			return true
+		}
+		if name != "integer" {
+			return false
+		}
+		if _, err := v.Int64(); err == nil {
+			return true
+		}
+		f, err := v.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+// typeNames describes the JSON types t allows, eg "a string or null".
+func typeNames(t any) string {
+	names := []string{}
+	switch t := t.(type) {
+	case string:
+		names = append(names, t)
+	case []any:
+		for _, t := range t {
+			if name, ok := t.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	for i, name := range names {
+		switch name {
+		case "null":
+		case "array", "integer", "object":
+			names[i] = "an " + name
+		default:
+			names[i] = "a " + name
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func schemaNumber(m map[string]any, key string) (float64, bool) {
+	n, ok := m[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func schemaInt(m map[string]any, key string) (int, bool) {
+	f, ok := schemaNumber(m, key)
+	return int(f), ok
+}
+
+// jsonEqual reports whether the decoded JSON values a and b are
+// equal. Numbers are compared by value, so 1 and 1.0 are equal.
+func jsonEqual(a, b any) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, _ := an.Float64()
+		bf, _ := bn.Float64()
+		return af == bf
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func jsonList(vs []any) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = jsonString(v)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,199 @@
+package jh
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const addressSchema = `{
+	"type": "object",
+	"required": ["name", "tags"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 8},
+		"age": {"type": "integer", "minimum": 0},
+		"code": {"type": ["string", "null"], "pattern": "^[A-Z]{2}$"},
+		"kind": {"enum": ["home", "work"]},
+		"tags": {"type": "array", "maxItems": 2, "uniqueItems": true, "items": {"type": "string"}},
+		"home": {"$ref": "#/$defs/place"}
+	},
+	"$defs": {
+		"place": {"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}}
+	}
+}`
+
+func TestSchemaCheck(t *testing.T) {
+	s, err := parseSchema([]byte(addressSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		body string
+		want map[string]any
+	}{
+		{`{"name":"ann","tags":[]}`, nil},
+		{`{"name":"ann","tags":["a"],"age":3,"code":"US","kind":"home","home":{"city":"sf"}}`, nil},
+		{`{"name":"ann","tags":[],"code":null}`, nil},
+		{`{"tags":[]}`, map[string]any{"name": "required"}},
+		{`{"name":"","tags":[]}`, map[string]any{"name": "must be at least 1 characters"}},
+		{`{"name":1,"tags":[]}`, map[string]any{"name": "must be a string"}},
+		{`{"name":"ann","tags":[],"age":1.5}`, map[string]any{"age": "must be an integer"}},
+		{`{"name":"ann","tags":[],"age":-1}`, map[string]any{"age": "must be >= 0"}},
+		{`{"name":"ann","tags":[],"code":"usa"}`, map[string]any{"code": `must match "^[A-Z]{2}$"`}},
+		{`{"name":"ann","tags":[],"kind":"boat"}`, map[string]any{"kind": `must be one of "home", "work"`}},
+		{`{"name":"ann","tags":["a","a",1]}`, map[string]any{
+			"tags":    "must have at most 2 items",
+			"tags[1]": "duplicates item 0",
+			"tags[2]": "must be a string",
+		}},
+		{`{"name":"ann","tags":[],"home":{}}`, map[string]any{"home.city": "required"}},
+		{`{"name":"ann","tags":[],"extra":1}`, map[string]any{"extra": "unknown field"}},
+		{`[]`, map[string]any{"body": "must be an object"}},
+		{`{`, nil}, // left to the decoder
+	}
+	for _, c := range cases {
+		err := s.check([]byte(c.body))
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("%s: got %v", c.body, err)
+			}
+			continue
+		}
+		e, ok := err.(Error)
+		if !ok || e.Code != 400 {
+			t.Errorf("%s: got %v want a 400 Error", c.body, err)
+			continue
+		}
+		if !reflect.DeepEqual(e.Details, c.want) {
+			t.Errorf("%s: got %v want %v", c.body, e.Details, c.want)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	cases := []struct {
+		schema string
+		ok     bool
+	}{
+		{`{}`, true},
+		{`true`, true},
+		{`{"properties":{"a":{"$ref":"#/$defs/a"}},"$defs":{"a":{}}}`, true},
+		{`{`, false},
+		{`{"pattern":"("}`, false},
+		{`{"$ref":"#/$defs/missing"}`, false},
+		{`{"$ref":"other.json"}`, false},
+	}
+	for _, c := range cases {
+		if _, err := parseSchema([]byte(c.schema)); (err == nil) != c.ok {
+			t.Errorf("%s: got %v", c.schema, err)
+		}
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+	h := HandlerFunc(echoPoint, WithSchema([]byte(`{
+		"type": "object",
+		"required": ["x"],
+		"properties": {"x": {"type": "integer", "maximum": 10}}
+	}`)))
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"x":1,"y":2}`, 200},
+		{`{"y":2}`, 400},
+		{`{"x":11}`, 400},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if rec.Code != c.status {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"x":11}`)))
+	var got Error
+	json.NewDecoder(rec.Body).Decode(&got)
+	if got.Message != "invalid x: must be <= 10" || got.Details["x"] != "must be <= 10" {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := Handler(echoPoint, nil, WithSchema([]byte(`{"pattern":"("}`))); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+}
+
+func TestSchemaKeywordNames(t *testing.T) {
+	// properties named like keywords are schemas too
+	s, err := parseSchema([]byte(`{"properties":{
+		"default": {"type": "string", "pattern": "^[a-z]+$"},
+		"enum": {"$defs": {"required": {"pattern": "^x"}}, "$ref": "#/properties/enum/$defs/required"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		body string
+		want map[string]any
+	}{
+		{`{"default":"abc","enum":"xy"}`, nil},
+		{`{"default":"ABC"}`, map[string]any{"default": `must match "^[a-z]+$"`}},
+		{`{"enum":"y"}`, map[string]any{"enum": `must match "^x"`}},
+	}
+	for _, c := range cases {
+		err := s.check([]byte(c.body))
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("%s: got %v", c.body, err)
+			}
+			continue
+		}
+		e, ok := err.(Error)
+		if !ok || !reflect.DeepEqual(e.Details, c.want) {
+			t.Errorf("%s: got %v want %v", c.body, err, c.want)
+		}
+	}
+	if _, err := parseSchema([]byte(`{"properties":{"default":{"pattern":"("}}}`)); err == nil {
+		t.Error("expected error for an invalid pattern under a property named default")
+	}
+}
+
+func TestSchemaDepth(t *testing.T) {
+	nested := func(open, leaf, close string, n int) string {
+		return strings.Repeat(open, n) + leaf + strings.Repeat(close, n)
+	}
+	cases := []struct {
+		schema, body string
+		want         map[string]any
+	}{
+		// deep documents without $refs
+		{nested(`{"items":`, `{"type":"integer"}`, `}`, 100), nested(`[`, `1`, `]`, 100), nil},
+		{nested(`{"items":`, `{"type":"integer"}`, `}`, 100), nested(`[`, `"x"`, `]`, 100), map[string]any{
+			strings.Repeat("[0]", 100): "must be an integer",
+		}},
+		// recursive schemas follow a $ref for each level
+		{`{"type":"array","items":{"$ref":"#"}}`, nested(`[`, ``, `]`, 100), nil},
+		{`{"$ref":"#"}`, `{}`, map[string]any{"body": "schema has a cycle of $refs"}},
+	}
+	for i, c := range cases {
+		s, err := parseSchema([]byte(c.schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.check([]byte(c.body))
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("case %d: got %v", i, err)
+			}
+			continue
+		}
+		if e, ok := err.(Error); !ok || !reflect.DeepEqual(e.Details, c.want) {
+			t.Errorf("case %d: got %v want %v", i, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// SSE returns a handler that streams Server-Sent Events
+// written by f. Each call to send writes an event, which is
+// flushed to the client right away. event is the event's
+// type and can be empty. data can span several lines.
+//
+// Errors returned by f before the first send are passed to
+// the error func, so f can check the request and fail with
+// an [Error] like a wrapped function. Once events have been
+// sent the status is already written so an error ends the
+// stream with an "error" event holding its message, which is
+// also sent in the [StreamErrorTrailer] trailer.
+//
+// The context passed to f is canceled when the client goes
+// away, after which send returns the context's error.
+//
+//	http.Handle("/events", SSE(func(ctx context.Context, send func(event, data string) error) error {
+//		for msg := range subscribe(ctx) {
+//			if err := send("message", msg); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	}))
+//
+// opts are applied as they are by [Handler].
+func SSE(
+	f func(ctx context.Context, send func(event, data string) error) error,
+	opts ...Option,
+) http.Handler {
+	h := newHandler(ErrHandler, opts)
+	h.noContent = true
+	h.call = func(ctx context.Context, _ any) (any, error) {
+		var (
+			w  = ResponseWriter(ctx)
+			rc = http.NewResponseController(w)
+			st = ctx.Value(stateKey).(*state)
+		)
+		send := func(event, data string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !st.streamed {
+				hdr := w.Header()
+				hdr.Set("Content-Type", "text/event-stream")
+				hdr.Set("Cache-Control", "no-cache")
+				w.WriteHeader(http.StatusOK)
+				st.streamed = true
+			}
+			if _, err := io.WriteString(w, formatEvent(event, data)); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		err := f(ctx, send)
+		if err == nil || !st.streamed {
+			return nil, err
+		}
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			return nil, nil // the client went away
+		}
+		st.err = err
+		if ctx.Err() == nil {
+			send("error", ErrorOf(err).Message)
+			setStreamError(w, err)
+		}
+		return nil, nil
+	}
+	return h
+}
+
+// StreamErrorTrailer is the trailer that reports an error which
+// ended a streamed response after its status was written, eg an
+// [SSE] stream or an [io.Reader] response that failed to read.
+// Clients that read trailers can use it to detect partial
+// responses. An empty StreamErrorTrailer turns it off.
+var StreamErrorTrailer = "X-Stream-Error"
+
+// setStreamError sets the [StreamErrorTrailer] of w
+// to the message clients get for err.
+func setStreamError(w http.ResponseWriter, err error) {
+	if StreamErrorTrailer == "" {
+		return
+	}
+	msg := ErrorOf(err).Message
+	w.Header().Set(http.TrailerPrefix+StreamErrorTrailer, strings.NewReplacer("\r", " ", "\n", " ").Replace(msg))
+}
+
+func formatEvent(event, data string) string {
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: ")
+		b.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(event))
+		b.WriteString("\n")
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
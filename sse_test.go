@@ -0,0 +1,105 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSSE(t *testing.T) {
+	cases := []struct {
+		f           func(ctx context.Context, send func(event, data string) error) error
+		wantStatus  int
+		wantBody    string
+		wantTrailer string
+	}{
+		{
+			func(ctx context.Context, send func(event, data string) error) error {
+				send("", "hello")
+				send("update", "a\nb")
+				return nil
+			},
+			200, "data: hello\n\nevent: update\ndata: a\ndata: b\n\n", "",
+		},
+		{
+			func(ctx context.Context, send func(event, data string) error) error {
+				if Request(ctx).URL.Query().Get("topic") == "" {
+					return BadRequest("missing topic")
+				}
+				return nil
+			},
+			400, "{\"message\":\"missing topic\"}\n", "",
+		},
+		{
+			func(ctx context.Context, send func(event, data string) error) error {
+				send("", "1")
+				return errors.New("lost upstream")
+			},
+			200, "data: 1\n\nevent: error\ndata: lost upstream\n\n", "lost upstream",
+		},
+	}
+	for i, c := range cases {
+		var (
+			r   = httptest.NewRequest("GET", "/events", nil)
+			rec = httptest.NewRecorder()
+		)
+		SSE(c.f).ServeHTTP(rec, r)
+		if rec.Code != c.wantStatus {
+			t.Errorf("case %d: got %d want %d", i, rec.Code, c.wantStatus)
+		}
+		if got := rec.Body.String(); got != c.wantBody {
+			t.Errorf("case %d: got = %q; want %q", i, got, c.wantBody)
+		}
+		if got := rec.Result().Trailer.Get(StreamErrorTrailer); got != c.wantTrailer {
+			t.Errorf("case %d: got trailer %q want %q", i, got, c.wantTrailer)
+		}
+		if c.wantStatus == 200 {
+			if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("case %d: got Content-Type %q", i, ct)
+			}
+			if !rec.Flushed {
+				t.Errorf("case %d: not flushed", i)
+			}
+		}
+	}
+}
+
+func TestSSECanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		r    = httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+		rec  = httptest.NewRecorder()
+		sent int
+	)
+	SSE(func(ctx context.Context, send func(event, data string) error) error {
+		for {
+			if err := send("", "tick"); err != nil {
+				return err
+			}
+			if sent++; sent == 2 {
+				cancel()
+			}
+		}
+	}).ServeHTTP(rec, r)
+	if want := "data: tick\n\ndata: tick\n\n"; rec.Body.String() != want {
+		t.Errorf("got = %q; want %q", rec.Body, want)
+	}
+}
+
+func TestSSEErrorMessage(t *testing.T) {
+	defer func(msg string) { DefaultErrorMessage = msg }(DefaultErrorMessage)
+	DefaultErrorMessage = "internal error"
+
+	rec := httptest.NewRecorder()
+	SSE(func(ctx context.Context, send func(event, data string) error) error {
+		send("", "1")
+		return errors.New("dial tcp 10.0.0.1:5432: connection refused")
+	}).ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	if got, want := rec.Body.String(), "data: 1\n\nevent: error\ndata: internal error\n\n"; got != want {
+		t.Errorf("got = %q; want %q", got, want)
+	}
+	if got := rec.Result().Trailer.Get(StreamErrorTrailer); got != "internal error" {
+		t.Errorf("got trailer %q want %q", got, "internal error")
+	}
+}
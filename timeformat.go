@@ -0,0 +1,227 @@
+package jh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeLayout returns the layout of sf's `time_format:"layout"`
+// tag when sf is a time.Time or a pointer to one.
+func timeLayout(sf reflect.StructField) (string, bool) {
+	layout, ok := sf.Tag.Lookup("time_format")
+	if !ok || layout == "" {
+		return "", false
+	}
+	t := sf.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return layout, t == timeType
+}
+
+// rfc3339Values parses vals with the layout of sf's time_format
+// tag and returns them as RFC 3339 times, so they're bound like
+// any time. vals are returned as is when sf has no layout.
+func rfc3339Values(sf reflect.StructField, vals []string) ([]string, error) {
+	layout, ok := timeLayout(sf)
+	if !ok {
+		return vals, nil
+	}
+	out := make([]string, len(vals))
+	for i, s := range vals {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a time like %q", s, layout)
+		}
+		out[i] = t.Format(time.RFC3339Nano)
+	}
+	return out, nil
+}
+
+// hasTimeFormat reports whether t, or a struct it refers to,
+// has a body field with a time_format tag.
+func hasTimeFormat(t reflect.Type) bool {
+	return hasTimeFormatSeen(t, map[reflect.Type]bool{})
+}
+
+func hasTimeFormatSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if isParam(sf) {
+			continue
+		}
+		if _, ok := timeLayout(sf); ok || hasTimeFormatSeen(sf.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTimes returns the JSON body data with the values of
+// fields tagged `time_format:"2006-01-02"` rewritten from the
+// tag's layout to RFC 3339, the only format encoding/json
+// decodes into a time.Time. Values that don't match the layout
+// are an [Error] with a 400 Code naming the fields like
+// [checkRequired] does. Other values are left alone.
+func formatTimes(t reflect.Type, data []byte) ([]byte, error) {
+	var (
+		raw     json.RawMessage
+		invalid = map[string]any{}
+	)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if dec.Decode(&raw) != nil {
+		// let the decoder report the error
+		return data, nil
+	}
+	out, changed := rewriteTimes(t, raw, "", invalid)
+	if len(invalid) > 0 {
+		names := make([]string, 0, len(invalid))
+		for name := range invalid {
+			names = append(names, fmt.Sprintf("%q", name))
+		}
+		sort.Strings(names)
+		msg := "invalid time in field "
+		if len(names) > 1 {
+			msg = "invalid times in fields "
+		}
+		return nil, Error{
+			Code:    http.StatusBadRequest,
+			Message: msg + strings.Join(names, ", "),
+			Details: invalid,
+		}
+	}
+	if !changed {
+		return data, nil
+	}
+	// keep trailing data for the decoder to reject
+	return append(out, data[dec.InputOffset():]...), nil
+}
+
+// rewriteTimes rewrites the times in raw, a JSON value for a t,
+// and reports whether it changed any. The paths of the fields
+// with invalid times are added to invalid.
+func rewriteTimes(t reflect.Type, raw json.RawMessage, path string, invalid map[string]any) (json.RawMessage, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && t != timeType:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil || !rewriteFields(t, obj, path, invalid) {
+			return raw, false
+		}
+		out, err := json.Marshal(obj)
+		if err != nil {
+			return raw, false
+		}
+		return out, true
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		var elems []json.RawMessage
+		if json.Unmarshal(raw, &elems) != nil {
+			return raw, false
+		}
+		changed := false
+		for i, e := range elems {
+			if out, ok := rewriteTimes(t.Elem(), e, fmt.Sprintf("%s[%d]", path, i), invalid); ok {
+				elems[i], changed = out, true
+			}
+		}
+		if !changed {
+			return raw, false
+		}
+		out, err := json.Marshal(elems)
+		if err != nil {
+			return raw, false
+		}
+		return out, true
+	}
+	return raw, false
+}
+
+func rewriteFields(t reflect.Type, obj map[string]json.RawMessage, path string, invalid map[string]any) bool {
+	changed := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if (!sf.IsExported() && !sf.Anonymous) || isParam(sf) {
+			continue
+		}
+		tag, _ := sf.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+			// encoding/json promotes the fields of embedded structs
+			if rewriteFields(ft, obj, path, invalid) {
+				changed = true
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		full := name
+		if path != "" {
+			full = path + "." + name
+		}
+		key, ok := findKey(obj, name)
+		if !ok {
+			continue
+		}
+		val := obj[key]
+		if layout, ok := timeLayout(sf); ok {
+			var s string
+			if json.Unmarshal(val, &s) != nil {
+				// null, or not a string for the decoder to reject
+				continue
+			}
+			tm, err := time.Parse(layout, s)
+			if err != nil {
+				invalid[full] = fmt.Sprintf("must be a time like %q", layout)
+				continue
+			}
+			obj[key], _ = json.Marshal(tm.Format(time.RFC3339Nano))
+			changed = true
+			continue
+		}
+		if out, ok := rewriteTimes(sf.Type, val, full, invalid); ok {
+			obj[key] = out
+			changed = true
+		}
+	}
+	return changed
+}
+
+// findKey returns the key of obj that matches name, preferring
+// an exact match but, like encoding/json, ignoring case otherwise.
+func findKey(obj map[string]json.RawMessage, name string) (string, bool) {
+	if _, ok := obj[name]; ok {
+		return name, true
+	}
+	for k := range obj {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
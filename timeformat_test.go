@@ -0,0 +1,89 @@
+package jh
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type booking struct {
+	Guest    string     `json:"guest"`
+	CheckIn  time.Time  `json:"check_in" time_format:"2006-01-02"`
+	CheckOut *time.Time `json:"check_out" time_format:"2006-01-02"`
+	Stays    []struct {
+		Night time.Time `json:"night" time_format:"02/01/2006"`
+	} `json:"stays"`
+	Created time.Time `json:"created"`
+	Since   time.Time `query:"since" time_format:"2006-01-02"`
+}
+
+func TestTimeFormat(t *testing.T) {
+	var got booking
+	h, _ := Handler(func(ctx context.Context, b booking) error {
+		got = b
+		return nil
+	}, ErrHandler)
+
+	cases := []struct {
+		target, body string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"/?since=2024-02-01", `{"guest":"a","check_in":"2024-03-01","check_out":"2024-03-04","stays":[{"night":"01/03/2024"}],"created":"2024-02-28T10:00:00Z"}`, 204, ""},
+		{"/", `{"check_in":"2024-03-01T00:00:00Z"}`, 400, `{"message":"invalid time in field \"check_in\"","details":{"check_in":"must be a time like \"2006-01-02\""}}`},
+		{"/", `{"check_in":"2024-03-01","stays":[{"night":"2024-03-01"}],"check_out":"x"}`, 400, `{"message":"invalid times in fields \"check_out\", \"stays[0].night\"","details":{"check_out":"must be a time like \"2006-01-02\"","stays[0].night":"must be a time like \"02/01/2006\""}}`},
+		{"/", `{"check_in":5}`, 400, ""},
+		{"/?since=yesterday", `{}`, 400, `{"message":"invalid query parameter \"since\": \"yesterday\" is not a time like \"2006-01-02\""}`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", c.target, strings.NewReader(c.body)))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); c.wantBody != "" && got != c.wantBody {
+			t.Errorf("%s: got = %s; want %s", c.body, got, c.wantBody)
+		}
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", cases[0].target, strings.NewReader(cases[0].body)))
+	day := func(month, d int) time.Time { return time.Date(2024, time.Month(month), d, 0, 0, 0, 0, time.UTC) }
+	if !got.CheckIn.Equal(day(3, 1)) || got.CheckOut == nil || !got.CheckOut.Equal(day(3, 4)) ||
+		len(got.Stays) != 1 || !got.Stays[0].Night.Equal(day(3, 1)) || !got.Since.Equal(day(2, 1)) ||
+		!got.Created.Equal(time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC)) || got.Guest != "a" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestTimeFormatTrailingData(t *testing.T) {
+	h, _ := Handler(func(ctx context.Context, b booking) error {
+		return nil
+	}, ErrHandler, DisallowTrailingData(true))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"check_in":"2024-03-01"} {}`)))
+	if rec.Code != 400 {
+		t.Errorf("got %d want 400", rec.Code)
+	}
+}
+
+func TestTimeFormatBatch(t *testing.T) {
+	type day struct {
+		Day time.Time `json:"day" time_format:"2006-01-02"`
+	}
+	h, err := Batch(func(ctx context.Context, d day) (*string, error) {
+		s := d.Day.Format(time.RFC3339)
+		return &s, nil
+	}, ErrHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`[{"day":"2024-01-02"},{"day":"01/02/2024"}]`)))
+	want := `[{"status":200,"body":"2024-01-02T00:00:00Z"},` +
+		`{"status":400,"error":{"message":"invalid time in field \"day\"","details":{"day":"must be a time like \"2006-01-02\""}}}]`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package jh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Tx is a transaction started by [WithTx], eg a *sql.Tx.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// WithTx returns middleware that runs each request in a
+// transaction. It calls begin with the request's context, so the
+// transaction ends with the request, and stores the Tx in the
+// context where wrapped functions can get it using [Transaction]:
+//
+//	h := Chain(HandlerFunc(transfer), WithTx(func(ctx context.Context) (Tx, error) {
+//		return db.BeginTx(ctx, nil)
+//	}, nil))
+//
+// The transaction is committed or rolled back once the response's
+// status is known and before it's written, so clients only see
+// a success that was committed. commitOn gets the status and the
+// error passed to the error func of the wrapped handler, when it
+// was returned by [Handler] or [HandlerFunc], and reports whether
+// to commit. A nil commitOn commits when err is nil and the status
+// is below 400. A failing Commit's error replaces the response: it
+// goes to the wrapped handler's error func, and is logged with the
+// request, or else to [ErrHandler]. A failing begin's goes to
+// ErrHandler. Panics roll back.
+func WithTx(begin func(ctx context.Context) (Tx, error), commitOn func(status int, err error) bool) func(http.Handler) http.Handler {
+	if commitOn == nil {
+		commitOn = func(status int, err error) bool {
+			return err == nil && status < 400
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tx, err := begin(r.Context())
+			if err != nil {
+				ErrHandler(r.Context(), w, fmt.Errorf("jh: beginning transaction: %w", err))
+				return
+			}
+			tw := &txWriter{ResponseWriter: w, r: r, tx: tx, commitOn: commitOn}
+			defer func() {
+				if !tw.decided {
+					// next panicked
+					tx.Rollback()
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), txKey, tw)))
+			tw.decide(http.StatusOK)
+		})
+	}
+}
+
+// Transaction returns the Tx that [WithTx] started for the
+// request. It returns nil for requests without one.
+func Transaction(ctx context.Context) Tx {
+	tw, ok := ctx.Value(txKey).(*txWriter)
+	if !ok {
+		return nil
+	}
+	return tw.tx
+}
+
+// txWriter ends a [WithTx] transaction when
+// its response's status is written.
+type txWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	tx       Tx
+	commitOn func(status int, err error) bool
+
+	// st is the state of the jh handler serving the request,
+	// which holds the error passed to its error func ef.
+	// ctx is the context the handler serves the request with.
+	st  *state
+	ef  func(context.Context, http.ResponseWriter, error)
+	ctx context.Context
+
+	decided bool
+	failed  bool // the commit failed and the response is dropped
+}
+
+// setTxState records st as the state, and ef as the error
+// func, of the request served with ctx if it's in a
+// [WithTx] transaction.
+func setTxState(ctx context.Context, st *state, ef func(context.Context, http.ResponseWriter, error)) {
+	if tw, ok := ctx.Value(txKey).(*txWriter); ok {
+		tw.st, tw.ef, tw.ctx = st, ef, ctx
+	}
+}
+
+// decide commits or rolls back the transaction for a response
+// with status, once. It reports whether the response can be
+// written, which it can't when the commit failed.
+func (w *txWriter) decide(status int) bool {
+	if w.decided {
+		return !w.failed
+	}
+	w.decided = true
+	var err error
+	if w.st != nil {
+		err = w.st.err
+	}
+	if !w.commitOn(status, err) {
+		w.tx.Rollback()
+		return true
+	}
+	if err := w.tx.Commit(); err != nil {
+		w.failed = true
+		w.fail(fmt.Errorf("jh: committing transaction: %w", err))
+		return false
+	}
+	return true
+}
+
+// responseHeaders describe the body of a response,
+// so they're dropped when an error replaces it.
+var responseHeaders = []string{
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"ETag",
+	"Last-Modified",
+	"Location",
+}
+
+// fail writes err's response in place of the one
+// the wrapped handler started to write.
+func (w *txWriter) fail(err error) {
+	hdr := w.Header()
+	for _, k := range responseHeaders {
+		hdr.Del(k)
+	}
+	if w.st == nil {
+		ErrHandler(w.r.Context(), w.ResponseWriter, err)
+		return
+	}
+	if w.st.err != nil {
+		err = errors.Join(w.st.err, err)
+	}
+	w.st.err = err
+	// the handler's writer recorded the status of the dropped response
+	rw := &responseWriter{ResponseWriter: w.ResponseWriter}
+	w.ef(w.ctx, rw, err)
+	w.st.rw.status = rw.status
+}
+
+func (w *txWriter) WriteHeader(code int) {
+	if code < 200 {
+		// informational responses come before the status
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.decide(code) {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *txWriter) Write(p []byte) (int, error) {
+	if !w.decide(http.StatusOK) {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets [http.ResponseController] reach w's
+// ResponseWriter, eg to set deadlines.
+func (w *txWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements [http.Flusher] when the
+// wrapped ResponseWriter supports flushing.
+func (w *txWriter) Flush() {
+	if w.decide(http.StatusOK) {
+		http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// Hijack implements [http.Hijacker] when the
+// wrapped ResponseWriter supports hijacking.
+func (w *txWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decide(http.StatusSwitchingProtocols)
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
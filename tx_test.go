@@ -0,0 +1,147 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTx struct {
+	commitErr error
+	ended     string
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.ended = "commit"
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.ended = "rollback"
+	return nil
+}
+
+func TestWithTx(t *testing.T) {
+	type req struct {
+		Fail   bool
+		Status int
+	}
+	h := HandlerFunc(func(ctx context.Context, r req) (*string, error) {
+		if Transaction(ctx) == nil {
+			return nil, errors.New("no transaction")
+		}
+		if r.Fail {
+			return nil, Conflict("taken")
+		}
+		if r.Status != 0 {
+			WithStatus(ctx, r.Status)
+		}
+		ok := "ok"
+		return &ok, nil
+	})
+
+	cases := []struct {
+		body       string
+		commitErr  error
+		commitOn   func(int, error) bool
+		wantEnded  string
+		wantStatus int
+	}{
+		{`{}`, nil, nil, "commit", 200},
+		{`{"Fail":true}`, nil, nil, "rollback", 409},
+		{`{"Status":202}`, nil, func(status int, err error) bool { return status == 200 }, "rollback", 202},
+		{`{"Fail":true}`, nil, func(status int, err error) bool { return status == 409 && err != nil }, "commit", 409},
+		{`{}`, errors.New("serialization failure"), nil, "commit", 500},
+	}
+	for _, c := range cases {
+		tx := &fakeTx{commitErr: c.commitErr}
+		begin := func(ctx context.Context) (Tx, error) { return tx, nil }
+		rec := httptest.NewRecorder()
+		Chain(h, WithTx(begin, c.commitOn)).ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if tx.ended != c.wantEnded {
+			t.Errorf("%s: got %q want %q", c.body, tx.ended, c.wantEnded)
+		}
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+		}
+	}
+
+	tx := &fakeTx{commitErr: errors.New("serialization failure")}
+	rec := httptest.NewRecorder()
+	Chain(h, WithTx(func(ctx context.Context) (Tx, error) { return tx, nil }, nil)).
+		ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"error":"jh: committing transaction: serialization failure"}` {
+		t.Errorf("got = %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	Chain(h, WithTx(func(ctx context.Context) (Tx, error) { return nil, errors.New("pool exhausted") }, nil)).
+		ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if rec.Code != 500 {
+		t.Errorf("got %d want 500", rec.Code)
+	}
+}
+
+func TestWithTxPanic(t *testing.T) {
+	tx := &fakeTx{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), WithTx(func(ctx context.Context) (Tx, error) { return tx, nil }, nil))
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if tx.ended != "rollback" {
+		t.Errorf("got %q want rollback", tx.ended)
+	}
+	if got := Transaction(context.Background()); got != nil {
+		t.Errorf("got %v want nil", got)
+	}
+}
+
+func TestWithTxCommitFailure(t *testing.T) {
+	var (
+		logged    error
+		logStatus int
+		efErr     error
+	)
+	SetLogger(func(ctx context.Context, r *http.Request, status int, dur time.Duration, err error) {
+		logStatus, logged = status, err
+	})
+	defer SetLogger(nil)
+
+	h := HandlerFunc(func(ctx context.Context, _ struct{}) (*string, error) {
+		Created(ctx, "/widgets/1")
+		s := "created"
+		return &s, nil
+	}, BufferResponse(true), ErrFunc(func(ctx context.Context, w http.ResponseWriter, err error) {
+		efErr = err
+		w.WriteHeader(503)
+		io.WriteString(w, "try again")
+	}))
+	tx := &fakeTx{commitErr: errors.New("serialization failure")}
+	rec := httptest.NewRecorder()
+	Chain(h, WithTx(func(ctx context.Context) (Tx, error) { return tx, nil }, nil)).
+		ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+
+	if rec.Code != 503 || rec.Body.String() != "try again" {
+		t.Errorf("got %d %q", rec.Code, rec.Body)
+	}
+	for _, k := range []string{"Content-Length", "Location", "Content-Type"} {
+		if v := rec.Header().Get(k); v != "" {
+			t.Errorf("got %s %q", k, v)
+		}
+	}
+	want := "jh: committing transaction: serialization failure"
+	if efErr == nil || efErr.Error() != want {
+		t.Errorf("error func got %v", efErr)
+	}
+	if logged == nil || logged.Error() != want || logStatus != 503 {
+		t.Errorf("logged %d %v", logStatus, logged)
+	}
+}
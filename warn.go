@@ -0,0 +1,50 @@
+package jh
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Warn adds a non-fatal warning to a successful response, eg
+// for an aggregation that is missing data from one source. Each
+// warning is sent in a Warning header with the 299 code:
+//
+//	Warning: 299 - "inventory service unavailable"
+//
+// Warnings are dropped when the wrapped function returns an
+// error. [Envelope] funcs can use [Warnings] to put them in
+// the response body too. Warn is safe to call from multiple
+// goroutines and does nothing outside of a handler.
+func Warn(ctx context.Context, msg string) {
+	st, ok := ctx.Value(stateKey).(*state)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.warnings = append(st.warnings, msg)
+}
+
+// Warnings returns the warnings added with [Warn].
+func Warnings(ctx context.Context) []string {
+	st, ok := ctx.Value(stateKey).(*state)
+	if !ok {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]string(nil), st.warnings...)
+}
+
+// writeWarnings adds a Warning header for each of st's warnings.
+func (st *state) writeWarnings(w http.ResponseWriter) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, msg := range st.warnings {
+		w.Header().Add("Warning", `299 - "`+warningQuoter.Replace(msg)+`"`)
+	}
+}
+
+// warningQuoter escapes the text of a Warning header's quoted-string.
+var warningQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
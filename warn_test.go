@@ -0,0 +1,65 @@
+package jh
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWarn(t *testing.T) {
+	var (
+		ctx = context.Background()
+		h   = HandlerFunc(func(ctx context.Context, r struct{ Fail bool }) (*struct{}, error) {
+			var wg sync.WaitGroup
+			for _, msg := range []string{"inventory unavailable", `prices "stale"`} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					Warn(ctx, msg)
+				}()
+				wg.Wait() // one at a time to keep their order
+			}
+			if r.Fail {
+				return nil, errors.New("boom")
+			}
+			return &struct{}{}, nil
+		}, Envelope(func(ctx context.Context, resp any) any {
+			return map[string]any{"data": resp, "warnings": Warnings(ctx)}
+		}))
+	)
+	Warn(ctx, "ignored")
+	if got := Warnings(ctx); got != nil {
+		t.Errorf("got %q want nil", got)
+	}
+
+	cases := []struct {
+		body, wantBody string
+		wantStatus     int
+		wantWarnings   []string
+	}{
+		{
+			`{}`,
+			`{"data":{},"warnings":["inventory unavailable","prices \"stale\""]}` + "\n",
+			200,
+			[]string{`299 - "inventory unavailable"`, `299 - "prices \"stale\""`},
+		},
+		{`{"Fail":true}`, `{"error":"boom"}` + "\n", 500, nil},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: got %d want %d", c.body, rec.Code, c.wantStatus)
+		}
+		if got := rec.Body.String(); got != c.wantBody {
+			t.Errorf("%s: got %s want %s", c.body, got, c.wantBody)
+		}
+		if got := rec.Header().Values("Warning"); !reflect.DeepEqual(got, c.wantWarnings) {
+			t.Errorf("%s: got %q want %q", c.body, got, c.wantWarnings)
+		}
+	}
+}
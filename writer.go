@@ -0,0 +1,88 @@
+package jh
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status and size of the
+// response written to the wrapped ResponseWriter.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets [http.ResponseController] reach w's
+// ResponseWriter, eg to flush it.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements [http.Flusher] when the
+// wrapped ResponseWriter supports flushing.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements [http.Hijacker] when the
+// wrapped ResponseWriter supports hijacking.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Size returns the number of body bytes written.
+func (w *responseWriter) Size() int64 {
+	return w.n
+}
+
+// Status returns the status that was written.
+// It's 200 when nothing was written, matching net/http.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// limitedBuffer keeps the first n bytes written to it
+// and discards the rest.
+type limitedBuffer struct {
+	buf []byte
+	n   int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.n - int64(len(b.buf)); room > 0 {
+		if int64(len(p)) > room {
+			b.buf = append(b.buf, p[:room]...)
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf
+}
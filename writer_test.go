@@ -0,0 +1,72 @@
+package jh
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// hijackRecorder is a ResponseRecorder
+// whose connection can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw := bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn))
+	return w.conn, rw, nil
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	var (
+		rec = httptest.NewRecorder()
+		w   = &responseWriter{ResponseWriter: rec}
+	)
+	var _ http.Flusher = w
+	w.Flush()
+	if !rec.Flushed {
+		t.Error("not flushed")
+	}
+	if got := w.Status(); got != 200 {
+		t.Errorf("got %d want 200", got)
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	h := SSE(func(ctx context.Context, send func(event, data string) error) error {
+		hj, ok := ResponseWriter(ctx).(http.Hijacker)
+		if !ok {
+			t.Error("ResponseWriter isn't a Hijacker")
+			return server.Close()
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		rw.WriteString("hijacked\n")
+		return rw.Flush()
+	})
+	go h.ServeHTTP(&hijackRecorder{httptest.NewRecorder(), server}, httptest.NewRequest("GET", "/", nil))
+
+	got, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(got) != "hijacked" {
+		t.Errorf("got %q want hijacked", got)
+	}
+
+	w := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := w.Hijack(); err == nil {
+		t.Error("expected error hijacking a ResponseRecorder")
+	}
+}